@@ -11,6 +11,7 @@ import (
 	"net"
 	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
 	"time"
 
@@ -18,7 +19,9 @@ import (
 	"github.com/fatih/color"
 	"github.com/ghdwlsgur/outline-vpn/internal"
 	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 type IPRange struct {
@@ -184,6 +187,15 @@ func decodeTerraformVarsFile() (string, error) {
 	}
 	json.NewDecoder(bytes.NewBuffer(buffer)).Decode(&_terraformVarsJSON)
 
+	// --yes provisions with the saved variables as they are
+	if viper.GetBool("apply-yes") {
+		_credential.awsConfig.Region = _terraformVarsJSON.AWSRegion
+		return "Yes", nil
+	}
+	if err := requireTerminal("yes"); err != nil {
+		return "", err
+	}
+
 	answer, err := internal.AskNewTfVars(
 		_terraformVarsJSON.AWSRegion,
 		_terraformVarsJSON.AvailabilityZone,
@@ -205,7 +217,7 @@ func isExistDefaultSubnet(ctx context.Context) error {
 	}
 
 	if !defaultSubnet.Existence {
-		answer, err := internal.AskCreateDefaultSubnet()
+		answer, err := askCreateDefaultNetwork("subnet", internal.AskCreateDefaultSubnet)
 		if err != nil {
 			return err
 		}
@@ -229,7 +241,7 @@ func isExistDefaultVpc(ctx context.Context) error {
 	}
 
 	if !defaultVpc.Existence {
-		answer, err := internal.AskCreateDefaultVpc()
+		answer, err := askCreateDefaultNetwork("vpc", internal.AskCreateDefaultVpc)
 		if err != nil {
 			return err
 		}
@@ -247,6 +259,49 @@ func isExistDefaultVpc(ctx context.Context) error {
 	return nil
 }
 
+// requireTerminal fails with the flag that replaces a prompt when nobody can answer it.
+func requireTerminal(flag string) error {
+	if !internal.IsTerminal() {
+		return fmt.Errorf("--%s is required when stdin is not a terminal", flag)
+	}
+	return nil
+}
+
+// askCreateDefaultNetwork answers Yes for --create-default-network, otherwise asks the user.
+func askCreateDefaultNetwork(resource string, ask func() (string, error)) (string, error) {
+	if viper.GetBool("apply-create-default-network") {
+		return "Yes", nil
+	}
+	if !internal.IsTerminal() {
+		return "", fmt.Errorf("there is no default %s in %s, --create-default-network creates it", resource, _credential.awsConfig.Region)
+	}
+	return ask()
+}
+
+// askApplyRegion asks the region to provision in, --region already set it.
+func askApplyRegion(ctx context.Context) error {
+	if viper.IsSet("region") {
+		return nil
+	}
+	if err := requireTerminal("region"); err != nil {
+		return err
+	}
+
+	fmt.Println(color.HiGreenString("The following region list represents the Active regions in my current AWS account."))
+	region, err := internal.AskRegion(ctx, *_credential.awsConfig)
+	if err != nil {
+		return err
+	}
+	_credential.awsConfig.Region = region.Name
+	return nil
+}
+
+// applyVariableFlags reports whether any of the terraform variables was given with flags,
+// the saved terraform.tfvars.json isn't offered then.
+func applyVariableFlags() bool {
+	return viper.IsSet("region") || viper.IsSet("apply-az") || viper.IsSet("apply-ami") || viper.IsSet("apply-instance-type")
+}
+
 func inputRegion(ctx context.Context) error {
 	if _credential.awsConfig.Region == "" {
 		region, err := internal.AskRegion(ctx, *_credential.awsConfig)
@@ -260,7 +315,19 @@ func inputRegion(ctx context.Context) error {
 }
 
 func inputAvailabilityZone(ctx context.Context) error {
+	if name := viper.GetString("apply-az"); name != "" {
+		if !strings.HasPrefix(name, _credential.awsConfig.Region) {
+			return fmt.Errorf("the availability zone %s is not in %s", name, _credential.awsConfig.Region)
+		}
+		_terraformVarsJSON.AvailabilityZone = name
+		return nil
+	}
+
 	if az == nil {
+		if err := requireTerminal("az"); err != nil {
+			return err
+		}
+
 		az, err := internal.AskAvailabilityZone(ctx, *_credential.awsConfig)
 		if err != nil {
 			return err
@@ -271,7 +338,16 @@ func inputAvailabilityZone(ctx context.Context) error {
 }
 
 func inputAmi(ctx context.Context) error {
+	if name := viper.GetString("apply-ami"); name != "" {
+		_terraformVarsJSON.EC2Ami = name
+		return nil
+	}
+
 	if ami == nil {
+		if err := requireTerminal("ami"); err != nil {
+			return err
+		}
+
 		ami, err = internal.AskAmi(ctx, *_credential.awsConfig)
 		if err != nil {
 			return err
@@ -282,7 +358,16 @@ func inputAmi(ctx context.Context) error {
 }
 
 func inputInstanceType(ctx context.Context) error {
+	if name := viper.GetString("apply-instance-type"); name != "" {
+		_terraformVarsJSON.InstanceType = name
+		return nil
+	}
+
 	if instanceType == nil {
+		if err := requireTerminal("instance-type"); err != nil {
+			return err
+		}
+
 		instanceType, err := internal.AskInstanceType(ctx, *_credential.awsConfig, _terraformVarsJSON.AvailabilityZone)
 		if err != nil {
 			return err
@@ -343,6 +428,95 @@ func inputTerraformVariable(ctx context.Context) error {
 	return nil
 }
 
+// planAndApply saves the plan of the workspace in planPath, shows it and
+// applies exactly that plan once confirmed. It reports whether the plan was
+// applied, the saved plan is removed in any case.
+func planAndApply(ctx context.Context, tf *tfexec.Terraform, planPath string) (bool, error) {
+	defer os.Remove(planPath)
+
+	changed, err := tf.Plan(ctx, tfexec.VarFile(_defaultTerraformVars), tfexec.Out(planPath))
+	if err != nil {
+		return false, fmt.Errorf("failed to terraform plan")
+	}
+	internal.PrintProvisioning("[workspace]", "terraform-plan:", "success")
+
+	if err = printPlan(ctx, tf, planPath); err != nil {
+		return false, err
+	}
+	if !changed {
+		fmt.Println("No changes, the EC2 instance and its resources are up to date")
+		return false, nil
+	}
+
+	answer := "Yes"
+	if !viper.GetBool("apply-yes") {
+		if err = requireTerminal("yes"); err != nil {
+			return false, err
+		}
+		answer, err = internal.AskTerraformExecution("Do You Provision EC2 Instance:")
+		if err != nil {
+			return false, err
+		}
+	}
+	if answer != "Yes" {
+		return false, nil
+	}
+
+	s := spinner.New(spinner.CharSets[8], 100*time.Millisecond)
+	s.UpdateCharSet(spinner.CharSets[59])
+	s.Color("fgHiGreen")
+	s.Restart()
+	s.Prefix = color.HiGreenString("EC2 Creating ")
+	defer s.Stop()
+
+	if err = tf.Apply(ctx, tfexec.DirOrPlan(planPath)); err != nil {
+		return false, fmt.Errorf("failed to terraform apply")
+	}
+	return true, nil
+}
+
+// printPlan reads the saved plan back and shows the resources it adds, changes or destroys.
+func printPlan(ctx context.Context, tf *tfexec.Terraform, planPath string) error {
+	plan, err := tf.ShowPlanFile(ctx, planPath)
+	if err != nil {
+		return fmt.Errorf("failed to terraform show %s: %s", planPath, err)
+	}
+
+	changes := internal.PlanChanges(plan)
+	if len(changes) == 0 {
+		return nil
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Action", "Resource", "Attributes"})
+
+	count := make(map[string]int)
+	for _, v := range changes {
+		count[v.Action]++
+
+		action := v.Action
+		switch v.Action {
+		case internal.PlanActionAdd:
+			action = color.GreenString(action)
+		case internal.PlanActionChange:
+			action = color.YellowString(action)
+		default:
+			action = color.RedString(action)
+		}
+		t.AppendRow(table.Row{action, v.Address, strings.Join(v.Attributes, "\n")})
+		t.AppendSeparator()
+	}
+	t.Render()
+
+	fmt.Printf("Plan: %d to add, %d to change, %d to replace, %d to destroy.\n",
+		count[internal.PlanActionAdd],
+		count[internal.PlanActionChange],
+		count[internal.PlanActionReplace],
+		count[internal.PlanActionDestroy])
+	return nil
+}
+
 var (
 	applyCommand = &cobra.Command{
 		Use:   "apply",
@@ -364,18 +538,15 @@ var (
 			}
 			s.Stop()
 
-			if _, err := os.Stat(_defaultTerraformVars); err == nil {
+			if _, err := os.Stat(_defaultTerraformVars); err == nil && !applyVariableFlags() {
 				answer, err := decodeTerraformVarsFile()
 				if err != nil {
 					panicRed(err)
 				}
 				if answer == "No" {
-					fmt.Println(color.HiGreenString("The following region list represents the Active regions in my current AWS account."))
-					askRegion, err := internal.AskRegion(ctx, *_credential.awsConfig)
-					if err != nil {
+					if err = askApplyRegion(ctx); err != nil {
 						panicRed(err)
 					}
-					_credential.awsConfig.Region = askRegion.Name
 
 					err = isExistDefaultVpc(ctx)
 					if err != nil {
@@ -388,12 +559,9 @@ var (
 					}
 				}
 			} else {
-				fmt.Println(color.HiGreenString("The following region list represents the Active regions in my current AWS account."))
-				askRegion, err := internal.AskRegion(ctx, *_credential.awsConfig)
-				if err != nil {
+				if err = askApplyRegion(ctx); err != nil {
 					panicRed(err)
 				}
-				_credential.awsConfig.Region = askRegion.Name
 
 				err = inputTerraformVariable(ctx)
 				if err != nil {
@@ -445,7 +613,15 @@ var (
 
 			// create tf file [ main.tf / key.tf / output.tf / provider.tf ]
 			workSpace.Path = _defaultTerraformPath + "/terraform.tfstate.d/" + _credential.awsConfig.Region
-			err = internal.CreateTf(workSpace.Path, _terraformVarsJSON.AWSRegion, _terraformVarsJSON.EC2Ami, _terraformVarsJSON.InstanceType, _terraformVarsJSON.AvailabilityZone)
+			backend, err := internal.LoadBackend(backendPath())
+			if err != nil {
+				panicRed(err)
+			}
+			module, err := internal.LoadModuleSource(moduleSourcePath())
+			if err != nil {
+				panicRed(err)
+			}
+			err = internal.CreateTf(workSpace.Path, _terraformVarsJSON.AWSRegion, _terraformVarsJSON.EC2Ami, _terraformVarsJSON.InstanceType, _terraformVarsJSON.AvailabilityZone, backend, module)
 			if err != nil {
 				panicRed(err)
 			}
@@ -463,34 +639,17 @@ var (
 
 			// terraform init [workspace] =============================================
 			if err = workSpaceTf.Init(ctx, tfexec.Upgrade(true)); err != nil {
-				panicRed(fmt.Errorf("failed to terraform init %s", err))
+				panicRed(fmt.Errorf("failed to terraform init %s\nif the backend changed, run `outline-vpn backend migrate -r %s`", err, _credential.awsConfig.Region))
 			}
 			internal.PrintProvisioning("[workspace]", "terraform-init:", "success")
 
-			// terraform plan [workspace] =============================================
-			if _, err = workSpaceTf.Plan(ctx, tfexec.VarFile(_defaultTerraformVars)); err != nil {
-				panicRed(fmt.Errorf("failed to terraform plan"))
-			}
-			internal.PrintProvisioning("[workspace]", "terraform-plan:", "success")
-
-			answer, err := internal.AskTerraformExecution("Do You Provision EC2 Instance:")
+			// terraform plan, apply [workspace] =============================================
+			applied, err := planAndApply(ctx, workSpaceTf, filepath.Join(workSpace.Path, internal.PlanFileName))
 			if err != nil {
 				panicRed(err)
 			}
 
-			if answer == "Yes" {
-				s := spinner.New(spinner.CharSets[8], 100*time.Millisecond)
-				s.UpdateCharSet(spinner.CharSets[59])
-				s.Color("fgHiGreen")
-				s.Restart()
-				s.Prefix = color.HiGreenString("EC2 Creating ")
-
-				// terraform apply [workspace] =============================================
-				err = workSpaceTf.Apply(ctx)
-				if err != nil {
-					panicRed(fmt.Errorf("failed to terraform apply"))
-				}
-
+			if applied {
 				ctx, cancel := context.WithTimeout(ctx, time.Minute)
 				defer cancel()
 
@@ -500,7 +659,6 @@ var (
 					panicRed(err)
 				}
 
-				s.Stop()
 				congratulation("🎉 Provisioning Complete! 🎉\n")
 				result := fmt.Sprintf("accessKey: %v\n", state.Values.Outputs["access_key"].Value)
 				congratulation(result)
@@ -535,5 +693,16 @@ var (
 )
 
 func init() {
+	applyCommand.Flags().StringP("az", "", "", "[optional] availability zone of the EC2 instance, e.g. us-east-1a")
+	applyCommand.Flags().StringP("ami", "", "", "[optional] id of the Amazon Machine Image of the EC2 instance")
+	applyCommand.Flags().StringP("instance-type", "", "", "[optional] instance type of the EC2 instance, e.g. t2.micro")
+	applyCommand.Flags().BoolP("create-default-network", "", false, "[optional] create the default vpc and subnet when they don't exist")
+	applyCommand.Flags().BoolP("yes", "y", false, "[optional] provision without confirmation")
+
+	viper.BindPFlag("apply-az", applyCommand.Flags().Lookup("az"))
+	viper.BindPFlag("apply-ami", applyCommand.Flags().Lookup("ami"))
+	viper.BindPFlag("apply-instance-type", applyCommand.Flags().Lookup("instance-type"))
+	viper.BindPFlag("apply-create-default-network", applyCommand.Flags().Lookup("create-default-network"))
+	viper.BindPFlag("apply-yes", applyCommand.Flags().Lookup("yes"))
 	rootCmd.AddCommand(applyCommand)
 }
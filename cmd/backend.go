@@ -0,0 +1,334 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/fatih/color"
+	"github.com/ghdwlsgur/outline-vpn/internal"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// backendPath is the backend configuration of every workspace.
+func backendPath() string {
+	return filepath.Join(_credential.homePath, "backend.json")
+}
+
+func showBackend() error {
+	backend, err := internal.LoadBackend(backendPath())
+	if err != nil {
+		return err
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Backend", "State", "Lock"})
+
+	switch backend.Type {
+	case internal.BackendS3:
+		t.AppendRow(table.Row{backend.Type,
+			fmt.Sprintf("s3://%s/%s (%s)", backend.Bucket, backend.Key("<region>"), backend.Region),
+			backend.LockTable})
+	case internal.BackendHTTP:
+		address, lockAddress, _ := backend.Addresses("<region>")
+		t.AppendRow(table.Row{backend.Type, address, lockAddress})
+	default:
+		t.AppendRow(table.Row{internal.BackendLocal, filepath.Join(_defaultTerraformPath, "terraform.tfstate.d", "<region>"), ""})
+	}
+
+	t.Render()
+	return nil
+}
+
+func setBackend(backendType string) error {
+	backend := &internal.Backend{Type: backendType}
+
+	switch backendType {
+	case internal.BackendS3:
+		backend.Bucket = viper.GetString("backend-bucket")
+		backend.Region = viper.GetString("backend-bucket-region")
+		backend.LockTable = viper.GetString("backend-lock-table")
+		backend.KeyPrefix = viper.GetString("backend-key-prefix")
+	case internal.BackendHTTP:
+		backend.Address = viper.GetString("backend-address")
+		backend.LockAddress = viper.GetString("backend-lock-address")
+		backend.UnlockAddress = viper.GetString("backend-unlock-address")
+	}
+
+	if err := backend.Save(backendPath()); err != nil {
+		return err
+	}
+
+	congratulation(fmt.Sprintf("Backend Update Success! (%s)\n", backendType))
+	notice("Run `outline-vpn backend migrate` to move the state of the existing workspaces\n")
+	return nil
+}
+
+// migrateBackend writes the backend of every workspace (or --region) and
+// lets terraform copy the state from the previous backend.
+func migrateBackend() error {
+	backend, err := internal.LoadBackend(backendPath())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	r, err := terraformReady(ctx, terraformVersion)
+	if err != nil {
+		return err
+	}
+
+	list, err := internal.GetWorkspaceList(ctx, r.execPath, _defaultTerraformPath)
+	if err != nil {
+		return err
+	}
+
+	if viper.IsSet("region") {
+		region := viper.GetString("region")
+		list = []string{}
+		if _, err := os.Stat(filepath.Join(_defaultTerraformPath, "terraform.tfstate.d", region)); err == nil {
+			list = append(list, region)
+		}
+	}
+
+	if len(list) == 0 {
+		fmt.Println("There is no workspace to migrate")
+		return nil
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Workspace", "Backend", "Status"})
+
+	failed := 0
+	for _, region := range list {
+		if err := migrateWorkspace(ctx, r.execPath, region, backend); err != nil {
+			failed++
+			t.AppendRow(table.Row{region, backend.Type, color.RedString("failed: %s", err)})
+			continue
+		}
+		t.AppendRow(table.Row{region, backend.Type, color.GreenString("migrated")})
+	}
+	t.Render()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d workspaces could not be migrated", failed, len(list))
+	}
+	congratulation(fmt.Sprintf("Migration Success! (%s)\n", backend.Type))
+	return nil
+}
+
+func migrateWorkspace(ctx context.Context, execPath, region string, backend *internal.Backend) error {
+	workSpacePath := filepath.Join(_defaultTerraformPath, "terraform.tfstate.d", region)
+	if _, err := os.Stat(filepath.Join(workSpacePath, "main.tf")); err != nil {
+		return fmt.Errorf("no terraform configuration, run `outline-vpn apply -r %s` first", region)
+	}
+
+	if err := internal.CreateBackendDotTf(workSpacePath, region, backend); err != nil {
+		return err
+	}
+
+	tf, err := internal.SetRoot(execPath, workSpacePath)
+	if err != nil {
+		return err
+	}
+
+	// -force-copy answers yes to copying the state into the new backend
+	return tf.Init(ctx, tfexec.ForceCopy(true))
+}
+
+// pullBackend writes the workspaces of the regions (every state of the s3
+// bucket by default) from the shared backend, so a teammate's machine can
+// list, manage and destroy the servers the others provisioned.
+func pullBackend(regions []string) error {
+	backend, err := internal.LoadBackend(backendPath())
+	if err != nil {
+		return err
+	}
+	if backend.IsLocal() {
+		return fmt.Errorf("the state is local, set the shared backend with `outline-vpn backend set` first")
+	}
+
+	module, err := internal.LoadModuleSource(moduleSourcePath())
+	if err != nil {
+		return err
+	}
+	// only the embedded module keeps outline.json in the state
+	if module.Type != internal.ModuleEmbedded {
+		return fmt.Errorf("backend pull needs the embedded module to restore outline.json from the state, the module source is %s (`outline-vpn module set embedded`)", module.Type)
+	}
+
+	ctx := context.Background()
+	if len(regions) == 0 {
+		if regions, err = internal.BackendRegions(ctx, *_credential.awsConfig, backend); err != nil {
+			return err
+		}
+	}
+
+	if len(regions) == 0 {
+		fmt.Println("There is no workspace in the backend")
+		return nil
+	}
+
+	r, err := terraformReady(ctx, terraformVersion)
+	if err != nil {
+		return err
+	}
+	if err = terraformInit(r, ctx); err != nil {
+		return err
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Workspace", "Backend", "Status"})
+
+	failed := 0
+	for _, region := range regions {
+		if err := pullWorkspace(ctx, r.execPath, region, backend, module); err != nil {
+			failed++
+			t.AppendRow(table.Row{region, backend.Type, color.RedString("failed: %s", err)})
+			continue
+		}
+		t.AppendRow(table.Row{region, backend.Type, color.GreenString("pulled")})
+	}
+	t.Render()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d workspaces could not be pulled", failed, len(regions))
+	}
+	congratulation(fmt.Sprintf("Pull Success! (%s)\n", backend.Type))
+	return nil
+}
+
+// pullWorkspace creates the workspace of the region, reads its state from the
+// backend and writes the terraform files and outline.json the server was
+// provisioned with.
+func pullWorkspace(ctx context.Context, execPath, region string, backend *internal.Backend, module *internal.ModuleSource) error {
+	workSpace, err := internal.ExistsWorkspace(ctx, execPath, _defaultTerraformPath, region)
+	if err != nil {
+		return err
+	}
+	if !workSpace.Existence {
+		if err = internal.CreateWorkspace(ctx, execPath, _defaultTerraformPath, region); err != nil {
+			return err
+		}
+	}
+
+	workSpacePath := filepath.Join(_defaultTerraformPath, "terraform.tfstate.d", region)
+	if err = internal.CreateBackendDotTf(workSpacePath, region, backend); err != nil {
+		return err
+	}
+
+	tf, err := internal.SetRoot(execPath, workSpacePath)
+	if err != nil {
+		return err
+	}
+
+	// the backend alone is enough to read the state
+	if err = tf.Init(ctx, tfexec.Reconfigure(true)); err != nil {
+		return err
+	}
+	state, err := tf.Show(ctx)
+	if err != nil {
+		return err
+	}
+
+	remote, err := internal.NewRemoteWorkspace(state)
+	if err != nil {
+		return err
+	}
+
+	if err = internal.CreateTf(workSpacePath, region, remote.EC2Ami, remote.InstanceType, remote.AvailabilityZone, backend, module); err != nil {
+		return err
+	}
+	if err = tf.Init(ctx, tfexec.Upgrade(true)); err != nil {
+		return err
+	}
+
+	return remote.WriteFiles(workSpacePath)
+}
+
+var (
+	backendCommand = &cobra.Command{
+		Use:   "backend",
+		Short: "Managing where terraform keeps the state of the workspaces",
+		Long:  "Managing where terraform keeps the state of the workspaces, an s3 bucket (with a dynamodb lock table) or an http backend lets a team share it",
+	}
+
+	backendShowCommand = &cobra.Command{
+		Use:   "show",
+		Short: "Show the backend of the terraform state",
+		Long:  "Show the backend of the terraform state",
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			if err := showBackend(); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+
+	backendSetCommand = &cobra.Command{
+		Use:       "set",
+		Short:     "Set the backend of the terraform state (local, s3, http)",
+		Long:      "Set the backend of the terraform state (local, s3, http), the credentials of the http backend are read from TF_HTTP_USERNAME and TF_HTTP_PASSWORD",
+		ValidArgs: []string{internal.BackendLocal, internal.BackendS3, internal.BackendHTTP},
+		Args:      cobra.MatchAll(internal.WrapArgsError(cobra.MinimumNArgs(1)), cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		Run: func(_ *cobra.Command, args []string) {
+			if err := setBackend(args[0]); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+
+	backendMigrateCommand = &cobra.Command{
+		Use:   "migrate",
+		Short: "Move the state of the workspaces into the configured backend",
+		Long:  "Move the state of every workspace (or the one of --region) into the configured backend with terraform init -force-copy",
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			if err := migrateBackend(); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+
+	backendPullCommand = &cobra.Command{
+		Use:   "pull [region...]",
+		Short: "Write the workspaces of the servers in the shared backend on this machine",
+		Long:  "Write the workspaces (terraform files and outline.json) of the regions from their state in the shared backend, every state of the s3 bucket without regions, so the servers a teammate provisioned can be listed, managed and destroyed here",
+		Run: func(_ *cobra.Command, args []string) {
+			if err := pullBackend(args); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+)
+
+func init() {
+	backendSetCommand.Flags().StringP("bucket", "", "", "[s3] bucket of the state")
+	backendSetCommand.Flags().StringP("bucket-region", "", "", "[s3] region of the bucket")
+	backendSetCommand.Flags().StringP("lock-table", "", "", "[s3] dynamodb table (partition key LockID) that locks the state")
+	backendSetCommand.Flags().StringP("key-prefix", "", "", "[s3] prefix of the state objects (default outline-vpn)")
+	backendSetCommand.Flags().StringP("address", "", "", "[http] address of the state, {region} is replaced by the workspace or the workspace is appended")
+	backendSetCommand.Flags().StringP("lock-address", "", "", "[http] address that locks the state, {region} is replaced by the workspace")
+	backendSetCommand.Flags().StringP("unlock-address", "", "", "[http] address that unlocks the state, {region} is replaced by the workspace")
+
+	viper.BindPFlag("backend-bucket", backendSetCommand.Flags().Lookup("bucket"))
+	viper.BindPFlag("backend-bucket-region", backendSetCommand.Flags().Lookup("bucket-region"))
+	viper.BindPFlag("backend-lock-table", backendSetCommand.Flags().Lookup("lock-table"))
+	viper.BindPFlag("backend-key-prefix", backendSetCommand.Flags().Lookup("key-prefix"))
+	viper.BindPFlag("backend-address", backendSetCommand.Flags().Lookup("address"))
+	viper.BindPFlag("backend-lock-address", backendSetCommand.Flags().Lookup("lock-address"))
+	viper.BindPFlag("backend-unlock-address", backendSetCommand.Flags().Lookup("unlock-address"))
+
+	backendCommand.AddCommand(backendShowCommand)
+	backendCommand.AddCommand(backendSetCommand)
+	backendCommand.AddCommand(backendMigrateCommand)
+	backendCommand.AddCommand(backendPullCommand)
+	rootCmd.AddCommand(backendCommand)
+}
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/fatih/color"
+	"github.com/ghdwlsgur/outline-vpn/internal"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// checkRegionQuotas compares the usage of every access key in the region with its data limit.
+func checkRegionQuotas(ctx context.Context, region string, warn float64) ([]internal.QuotaAlert, error) {
+	client, err := newOutlineClient(region)
+	if err != nil {
+		return nil, err
+	}
+
+	server, err := client.GetServer(ctx)
+	if err != nil {
+		return nil, outlineError(region, err)
+	}
+
+	accessKeys, err := client.GetAccessKeys(ctx)
+	if err != nil {
+		return nil, outlineError(region, err)
+	}
+
+	metrics, err := client.GetTransferMetrics(ctx)
+	if err != nil {
+		return nil, outlineError(region, err)
+	}
+
+	var defaultLimit *int
+	if server.AccessKeyDataLimit != nil {
+		defaultLimit = &server.AccessKeyDataLimit.Bytes
+	}
+
+	return internal.CheckQuotas(region, internal.JoinKeyUsage(accessKeys, metrics), defaultLimit, warn), nil
+}
+
+func checkQuotas() error {
+	warn, err := internal.ParsePercent(viper.GetString("check-warn"))
+	if err != nil {
+		return err
+	}
+
+	webhook := viper.GetString("check-webhook")
+	if webhook == "" {
+		webhook = os.Getenv("OUTLINE_VPN_WEBHOOK")
+	}
+
+	ctx := context.Background()
+	list, err := internal.ValidateOutlineJson(ctx, terraformVersion, _defaultTerraformPath)
+	if err != nil {
+		return err
+	}
+
+	if len(list) == 0 {
+		return fmt.Errorf("there is no outline server, run `outline-vpn apply` first")
+	}
+
+	// without --region every workspace is checked, so it can run from cron
+	if region := viper.GetString("region"); region != "" {
+		if !slices.Contains(list, region) {
+			return fmt.Errorf("there is no outline server in %s", region)
+		}
+		list = []string{region}
+	}
+
+	var (
+		alerts   = make([]internal.QuotaAlert, 0)
+		errCount int
+	)
+	for _, region := range list {
+		regionAlerts, err := checkRegionQuotas(ctx, region, warn)
+		if err != nil {
+			errCount++
+			fmt.Println(color.RedString("[failed] %s: %s", region, err))
+			continue
+		}
+		alerts = append(alerts, regionAlerts...)
+	}
+
+	if len(alerts) > 0 {
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+
+		t.AppendHeader(table.Row{"Status", "ID", "Name", "Bytes Used", "Data Limit", "% of Limit", "Region"})
+		for _, v := range alerts {
+			status := color.YellowString(v.Status)
+			if v.Status == internal.QuotaExceeded {
+				status = color.RedString(v.Status)
+			}
+			t.AppendRow(table.Row{status, v.ID, v.Name, internal.FormatDataSize(v.BytesTransferred),
+				internal.FormatDataSize(v.DataLimit), fmt.Sprintf("%.1f%%", v.PercentOfLimit), v.Region})
+		}
+
+		t.Render()
+
+		if webhook != "" {
+			if err = internal.PostQuotaAlerts(ctx, webhook, alerts); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch {
+	case errCount > 0:
+		return fmt.Errorf("%d of %d outline servers could not be checked", errCount, len(list))
+	case len(alerts) > 0:
+		return fmt.Errorf("%d access keys used more than %.0f%% of their data limit", len(alerts), warn)
+	}
+	congratulation("Every access key is within its quota!\n")
+
+	return nil
+}
+
+var (
+	checkCommand = &cobra.Command{
+		Use:       "check",
+		Short:     "Checking the outline resources",
+		Long:      "Checking the outline resources. It exits with 1 when a check fails, so it can run from cron.",
+		ValidArgs: []string{"quotas"},
+		Args:      cobra.MatchAll(internal.WrapArgsError(cobra.MinimumNArgs(1)), cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		Run: func(_ *cobra.Command, args []string) {
+			var (
+				err error
+			)
+
+			switch args[0] {
+			case "quotas":
+				if err = checkQuotas(); err != nil {
+					panicRed(err)
+				}
+			}
+		},
+	}
+)
+
+func init() {
+	checkCommand.Flags().StringP("warn", "", "80%", "[optional] percent of the data limit that raises an alert")
+	checkCommand.Flags().StringP("webhook", "", "", "[optional] url to POST the alerts as json (default is OUTLINE_VPN_WEBHOOK environment variable)")
+
+	viper.BindPFlag("check-warn", checkCommand.Flags().Lookup("warn"))
+	viper.BindPFlag("check-webhook", checkCommand.Flags().Lookup("webhook"))
+	rootCmd.AddCommand(checkCommand)
+}
@@ -2,14 +2,44 @@ package cmd
 
 import (
 	"context"
+	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/ghdwlsgur/outline-vpn/internal"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
-func createAccessURL() error {
+// newAccessKeyTemplate builds the access key to create from the create flags.
+func newAccessKeyTemplate() (*internal.AccessKey, error) {
+	template := &internal.AccessKey{
+		ID:       viper.GetString("create-id"),
+		Name:     viper.GetString("create-name"),
+		Method:   viper.GetString("create-method"),
+		Password: viper.GetString("create-password"),
+		Port:     viper.GetInt("create-port"),
+	}
+
+	if limit := viper.GetString("create-limit"); limit != "" {
+		bytes, err := internal.ParseDataSize(limit)
+		if err != nil {
+			return nil, err
+		}
+		template.DataLimit = &internal.DataLimit{Bytes: bytes}
+	}
+
+	return template, nil
+}
+
+func createAccessURLFromRoster(path string) error {
+	entries, err := internal.ReadRoster(path)
+	if err != nil {
+		return err
+	}
 
 	ctx := context.Background()
 	list, err := internal.ValidateOutlineJson(ctx, terraformVersion, _defaultTerraformPath)
@@ -17,25 +47,131 @@ func createAccessURL() error {
 		return err
 	}
 
-	answer, err := internal.AskPromptOptionList("Choose a Workspace (Region):", list, 10)
+	clients := make(map[string]*internal.OutlineClient)
+	for _, region := range list {
+		client, err := newOutlineClient(region)
+		if err != nil {
+			return err
+		}
+		clients[region] = client
+	}
+
+	results := internal.ApplyRoster(ctx, clients, entries)
+
+	resultPath := viper.GetString("create-result")
+	if resultPath == "" {
+		resultPath = strings.TrimSuffix(path, filepath.Ext(path)) + ".result.json"
+	}
+	if err = internal.WriteRosterResult(results, resultPath); err != nil {
+		return err
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+
+	failed := 0
+	t.AppendHeader(table.Row{"Name", "Region", "ID", "AccessURL", "Status"})
+	for _, v := range results {
+		status := v.Status
+		if v.Status == internal.RosterFailed {
+			failed++
+			status = fmt.Sprintf("%s (%s)", v.Status, v.Error)
+		}
+		t.AppendRow(table.Row{v.Name, v.Region, v.ID, v.AccessURL, status})
+	}
+
+	t.Render()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d access keys failed, see %s", failed, len(results), resultPath)
+	}
+	congratulation(fmt.Sprintf("Roster Applied! (%s)\n", resultPath))
+
+	return nil
+}
+
+func createAccessURL() error {
+	var (
+		ttl time.Duration
+		err error
+	)
+
+	if value := viper.GetString("create-ttl"); value != "" {
+		if ttl, err = internal.ParseDuration(value); err != nil {
+			return err
+		}
+		if ttl == 0 {
+			return fmt.Errorf("invalid ttl %q", value)
+		}
+	}
+
+	metadata := &internal.KeyMetadata{}
+	hasMetadata := setKeyMetadata("create", metadata)
+
+	if path := viper.GetString("create-from"); path != "" {
+		if ttl > 0 || hasMetadata {
+			return fmt.Errorf("--ttl, --owner, --email, --tag and --notes can't be used with --from")
+		}
+		return createAccessURLFromRoster(path)
+	}
+
+	template, err := newAccessKeyTemplate()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	answer, err := askWorkspace(ctx)
 	if err != nil {
 		return err
 	}
 
-	accessKey, err := internal.CreateAccessKey(answer)
+	client, err := newOutlineClient(answer)
 	if err != nil {
 		return err
 	}
 
+	// the registry is read before the access key is created, a key must not
+	// be left on the server without the expiry or metadata it was asked with
+	var registry *internal.KeyRegistry
+	if ttl > 0 || hasMetadata {
+		if registry, err = loadKeyRegistry(ctx, answer, client); err != nil {
+			return err
+		}
+	}
+
+	accessKey, err := client.CreateAccessKey(ctx, template)
+	if err != nil {
+		return outlineError(answer, err)
+	}
+
+	expires := "-"
+	if ttl > 0 {
+		expiresAt := time.Now().Add(ttl).UTC()
+		metadata.ExpiresAt = &expiresAt
+		expires = expiresAt.Format(time.RFC3339)
+	}
+
+	if registry != nil {
+		*registry.Get(accessKey.ID) = *metadata
+		if err = registry.Save(internal.KeyRegistryPath(answer)); err != nil {
+			if deleteErr := client.DeleteAccessKey(ctx, accessKey.ID); deleteErr != nil {
+				return fmt.Errorf("%w, and the access key %s could not be deleted: %s", err, accessKey.ID, deleteErr)
+			}
+			return fmt.Errorf("%w, the access key %s was deleted", err, accessKey.ID)
+		}
+	}
+
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
 
-	t.AppendHeader(table.Row{"ID", "AccessURL", "Password", "Region"})
-	t.AppendRow(table.Row{accessKey.ID, accessKey.AccessURL, accessKey.Password, answer})
+	t.AppendHeader(table.Row{"ID", "Name", "AccessURL", "Password", "Data Limit", "Owner", "Tags", "Expires", "Region"})
+	t.AppendRow(table.Row{accessKey.ID, accessKey.Name, accessKey.AccessURL, accessKey.Password, accessKey.GetDataLimit(),
+		metadata.GetOwner(), metadata.GetTags(), expires, answer})
 
 	t.Render()
 
-	return nil
+	return printQRCodes(answer, []internal.AccessKey{*accessKey}, viper.GetBool("create-qr"), viper.GetString("create-qr-dir"))
 }
 
 var (
@@ -52,7 +188,7 @@ var (
 
 			switch args[0] {
 			case "accesskey":
-				if createAccessURL(); err != nil {
+				if err = createAccessURL(); err != nil {
 					panicRed(err)
 				}
 			}
@@ -61,5 +197,36 @@ var (
 )
 
 func init() {
+	createCommand.Flags().StringP("name", "", "", "[optional] name of the access key")
+	createCommand.Flags().StringP("limit", "", "", "[optional] data limit of the access key in human units (e.g. 20GB)")
+	createCommand.Flags().StringP("method", "", "", "[optional] shadowsocks cipher (e.g. chacha20-ietf-poly1305, default is chosen by the server)")
+	createCommand.Flags().StringP("password", "", "", "[optional] shadowsocks secret (default is generated by the server)")
+	createCommand.Flags().IntP("port", "", 0, "[optional] port of the access key (default is the port for new access keys)")
+	createCommand.Flags().StringP("id", "", "", "[optional] id of the access key (default is chosen by the server)")
+	createCommand.Flags().StringP("ttl", "", "", "[optional] lifetime of the access key (e.g. 72h, 7d), prune accesskey deletes it afterwards")
+	createCommand.Flags().StringP("owner", "", "", "[optional] owner of the access key, kept locally")
+	createCommand.Flags().StringP("email", "", "", "[optional] email of the owner, kept locally")
+	createCommand.Flags().StringSliceP("tag", "", nil, "[optional] team tags of the access key (e.g. sales,eu), kept locally")
+	createCommand.Flags().StringP("notes", "", "", "[optional] free-form notes about the access key, kept locally")
+	createCommand.Flags().BoolP("qr", "", false, "[optional] print the access url as a QR code")
+	createCommand.Flags().StringP("qr-dir", "", "", "[optional] directory to write the QR code png of the access key")
+	createCommand.Flags().StringP("from", "", "", "[optional] roster file (csv or yaml) with name, region and limit of the access keys to create")
+	createCommand.Flags().StringP("result", "", "", "[optional] result file of --from mapping names to access urls (default is <roster>.result.json)")
+
+	viper.BindPFlag("create-name", createCommand.Flags().Lookup("name"))
+	viper.BindPFlag("create-limit", createCommand.Flags().Lookup("limit"))
+	viper.BindPFlag("create-method", createCommand.Flags().Lookup("method"))
+	viper.BindPFlag("create-password", createCommand.Flags().Lookup("password"))
+	viper.BindPFlag("create-port", createCommand.Flags().Lookup("port"))
+	viper.BindPFlag("create-id", createCommand.Flags().Lookup("id"))
+	viper.BindPFlag("create-ttl", createCommand.Flags().Lookup("ttl"))
+	viper.BindPFlag("create-owner", createCommand.Flags().Lookup("owner"))
+	viper.BindPFlag("create-email", createCommand.Flags().Lookup("email"))
+	viper.BindPFlag("create-tag", createCommand.Flags().Lookup("tag"))
+	viper.BindPFlag("create-notes", createCommand.Flags().Lookup("notes"))
+	viper.BindPFlag("create-qr", createCommand.Flags().Lookup("qr"))
+	viper.BindPFlag("create-qr-dir", createCommand.Flags().Lookup("qr-dir"))
+	viper.BindPFlag("create-from", createCommand.Flags().Lookup("from"))
+	viper.BindPFlag("create-result", createCommand.Flags().Lookup("result"))
 	rootCmd.AddCommand(createCommand)
 }
@@ -3,58 +3,242 @@ package cmd
 import (
 	"context"
 	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/fatih/color"
 
 	"github.com/ghdwlsgur/outline-vpn/internal"
+	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
-func deleteAccessURL() error {
+// askAccessKey lets the user choose one of the access keys of an outline server.
+func askAccessKey(accessKeys *internal.AccessKeys, message string) (*internal.AccessKey, error) {
 	var (
 		tableOption = make(map[string]*internal.AccessKey)
 	)
 
+	for i, v := range accessKeys.Keys {
+		tableOption[fmt.Sprintf("ID: %s, (%s)", v.ID, v.AccessURL)] = &accessKeys.Keys[i]
+	}
+
+	options := make([]string, 0, len(tableOption))
+	for v := range tableOption {
+		options = append(options, v)
+	}
+	sort.Strings(options)
+
+	if !internal.IsTerminal() {
+		return nil, fmt.Errorf("the access key must be given with flags (see --help) when stdin is not a terminal")
+	}
+
+	answer, err := internal.AskPromptOptionList(message, options, 10)
+	if err != nil {
+		return nil, err
+	}
+
+	return tableOption[answer], nil
+}
+
+// selectAccessKeys picks every key with all, the keys matching id and name,
+// or asks for one key when none of them is given.
+func selectAccessKeys(accessKeys *internal.AccessKeys, id, name string, all bool, message string) (*internal.AccessKeys, error) {
+	switch {
+	case all:
+		return accessKeys, nil
+	case id != "" || name != "":
+		accessKeys = accessKeys.Filter(id, name)
+		if len(accessKeys.Keys) == 0 {
+			return nil, fmt.Errorf("no access key matches --id %q --name %q", id, name)
+		}
+		return accessKeys, nil
+	}
+
+	accessKey, err := askAccessKey(accessKeys, message)
+	if err != nil {
+		return nil, err
+	}
+	return &internal.AccessKeys{Keys: []internal.AccessKey{*accessKey}}, nil
+}
+
+// askAccessKeys lets the user choose any number of the access keys of an outline server.
+func askAccessKeys(accessKeys *internal.AccessKeys, registry *internal.KeyRegistry, message string) (*internal.AccessKeys, error) {
+	if !internal.IsTerminal() {
+		return nil, fmt.Errorf("the access keys must be given with flags (see --help) when stdin is not a terminal")
+	}
+
+	var (
+		options     = make([]string, 0, len(accessKeys.Keys))
+		tableOption = make(map[string]internal.AccessKey)
+	)
+	for _, v := range accessKeys.Keys {
+		option := fmt.Sprintf("ID: %s, %s", v.ID, v.Name)
+		if metadata := registry.Lookup(v.ID); metadata != nil && metadata.Owner != "" {
+			option = fmt.Sprintf("%s (%s)", option, metadata.Owner)
+		}
+		options = append(options, option)
+		tableOption[option] = v
+	}
+
+	answer, err := internal.AskPromptMultiSelect(message, options, 15)
+	if err != nil {
+		return nil, err
+	}
+
+	selected := &internal.AccessKeys{Keys: make([]internal.AccessKey, 0, len(answer))}
+	for _, v := range answer {
+		selected.Keys = append(selected.Keys, tableOption[v])
+	}
+	return selected, nil
+}
+
+// deleteCandidates narrows the access keys down with the delete flags, every
+// given flag has to match. Without any flag the user chooses them.
+func deleteCandidates(ctx context.Context, region string, client *internal.OutlineClient, accessKeys *internal.AccessKeys, registry *internal.KeyRegistry) (*internal.AccessKeys, error) {
+	var (
+		id        = viper.GetString("delete-id")
+		name      = viper.GetString("delete-name")
+		pattern   = viper.GetString("delete-name-pattern")
+		unusedFor = viper.GetString("delete-unused-for")
+		except    = viper.GetStringSlice("delete-all-except")
+		err       error
+	)
+
+	if !viper.GetBool("delete-all") && id == "" && name == "" && pattern == "" && unusedFor == "" && len(except) == 0 {
+		return askAccessKeys(accessKeys, registry, "Please select the access keys you want to delete:")
+	}
+
+	selected := accessKeys.Filter(id, name)
+	if len(selected.Keys) == 0 {
+		return nil, fmt.Errorf("no access key matches --id %q --name %q", id, name)
+	}
+
+	if pattern != "" {
+		if selected, err = selected.FilterNamePattern(pattern); err != nil {
+			return nil, err
+		}
+	}
+
+	if unusedFor != "" {
+		d, err := internal.ParseDuration(unusedFor)
+		if err != nil {
+			return nil, err
+		}
+
+		now := time.Now()
+		metrics, err := client.GetTransferMetrics(ctx)
+		if err != nil {
+			return nil, outlineError(region, err)
+		}
+
+		store, err := internal.OpenUsageStore(usageStorePath())
+		if err != nil {
+			return nil, err
+		}
+		defer store.Close()
+
+		snapshots, err := store.Snapshots()
+		if err != nil {
+			return nil, err
+		}
+
+		if selected, err = internal.UnusedAccessKeys(snapshots, region, registry.ServerID, now.Add(-d), now, selected, metrics); err != nil {
+			return nil, err
+		}
+	}
+
+	return selected.Except(except), nil
+}
+
+func deleteAccessURL() error {
 	ctx := context.Background()
-	list, err := internal.ValidateOutlineJson(ctx, terraformVersion, _defaultTerraformPath)
+	region, err := askWorkspace(ctx)
 	if err != nil {
 		return err
 	}
 
-	region, err := internal.AskPromptOptionList("Choose a Workspace (Region):", list, 10)
+	client, err := newOutlineClient(region)
 	if err != nil {
 		return err
 	}
 
-	accessKeys, err := internal.GetAccessKeys(region)
+	accessKeys, err := client.GetAccessKeys(ctx)
+	if err != nil {
+		return outlineError(region, err)
+	}
+
+	if len(accessKeys.Keys) == 0 {
+		fmt.Println("The access key does not exist")
+		return nil
+	}
+
+	registry, err := loadKeyRegistry(ctx, region, client)
 	if err != nil {
 		return err
 	}
 
-	if len(accessKeys.Keys) > 0 {
-		for _, v := range accessKeys.Keys {
-			tableOption[fmt.Sprintf("ID: %s, (%s)", v.ID, v.AccessURL)] = &internal.AccessKey{
-				ID:        v.ID,
-				AccessURL: v.AccessURL,
-			}
-		}
+	accessKeys, err = deleteCandidates(ctx, region, client, accessKeys, registry)
+	if err != nil {
+		return err
+	}
 
-		options := make([]string, 0, len(tableOption))
-		for v := range tableOption {
-			options = append(options, v)
+	if len(accessKeys.Keys) == 0 {
+		fmt.Println("No access key matches")
+		return nil
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"ID", "Name", "Owner", "Tags", "Region"})
+	for _, v := range accessKeys.Keys {
+		metadata := registry.Lookup(v.ID)
+		if metadata == nil {
+			metadata = &internal.KeyMetadata{}
 		}
+		t.AppendRow(table.Row{v.ID, v.Name, metadata.GetOwner(), metadata.GetTags(), region})
+	}
+	t.Render()
 
-		answer, err := internal.AskPromptOptionList("Please select the access key you want to delete:", options, 10)
+	if !viper.GetBool("delete-yes") {
+		if err := requireTerminal("yes"); err != nil {
+			return err
+		}
+		answer, err := internal.AskPrompt(fmt.Sprintf("Do You Delete %d Access Keys:", len(accessKeys.Keys)), "Yes", "No (exit)")
 		if err != nil {
 			return err
 		}
+		if answer != "Yes" {
+			return nil
+		}
+	}
+
+	result := table.NewWriter()
+	result.SetOutputMirror(os.Stdout)
+	result.AppendHeader(table.Row{"ID", "Name", "Status", "Region"})
 
-		err = internal.DeleteAccessKey(region, tableOption[answer].ID)
-		if err == nil {
-			congratulation("Delete Success!\n")
+	failed := 0
+	for _, v := range accessKeys.Keys {
+		if err = client.DeleteAccessKey(ctx, v.ID); err != nil {
+			failed++
+			result.AppendRow(table.Row{v.ID, v.Name, color.RedString("failed: %s", outlineError(region, err)), region})
+			continue
 		}
+		registry.Delete(v.ID)
+		result.AppendRow(table.Row{v.ID, v.Name, color.GreenString("deleted"), region})
+	}
+	result.Render()
 
-	} else {
-		fmt.Println("The access key does not exist")
+	if err = registry.Save(internal.KeyRegistryPath(region)); err != nil {
+		return err
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d access keys could not be deleted", failed, len(accessKeys.Keys))
 	}
+	congratulation(fmt.Sprintf("Delete Success! (%d access keys)\n", len(accessKeys.Keys)))
 
 	return nil
 }
@@ -73,7 +257,7 @@ var (
 
 			switch args[0] {
 			case "accesskey":
-				if deleteAccessURL(); err != nil {
+				if err = deleteAccessURL(); err != nil {
 					panicRed(err)
 				}
 			}
@@ -82,5 +266,21 @@ var (
 )
 
 func init() {
+	deleteCommand.Flags().StringP("id", "", "", "[optional] id of the access key to delete")
+	deleteCommand.Flags().StringP("name", "", "", "[optional] name of the access keys to delete")
+	deleteCommand.Flags().BoolP("all", "", false, "[optional] delete every access key of the outline server")
+
+	deleteCommand.Flags().StringP("name-pattern", "", "", "[optional] glob pattern of the names of the access keys to delete, e.g. 'guest-*'")
+	deleteCommand.Flags().StringP("unused-for", "", "", "[optional] delete the access keys that transferred nothing for a duration, e.g. 30d (needs metrics snapshots)")
+	deleteCommand.Flags().StringSliceP("all-except", "", nil, "[optional] ids or names of the access keys to keep")
+	deleteCommand.Flags().BoolP("yes", "y", false, "[optional] delete without confirmation")
+
+	viper.BindPFlag("delete-id", deleteCommand.Flags().Lookup("id"))
+	viper.BindPFlag("delete-name", deleteCommand.Flags().Lookup("name"))
+	viper.BindPFlag("delete-all", deleteCommand.Flags().Lookup("all"))
+	viper.BindPFlag("delete-name-pattern", deleteCommand.Flags().Lookup("name-pattern"))
+	viper.BindPFlag("delete-unused-for", deleteCommand.Flags().Lookup("unused-for"))
+	viper.BindPFlag("delete-all-except", deleteCommand.Flags().Lookup("all-except"))
+	viper.BindPFlag("delete-yes", deleteCommand.Flags().Lookup("yes"))
 	rootCmd.AddCommand(deleteCommand)
 }
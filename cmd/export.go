@@ -0,0 +1,281 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ghdwlsgur/outline-vpn/internal"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// exportAccessKeys returns the workspace and the access keys chosen by the export flags.
+func exportAccessKeys(ctx context.Context) (string, *internal.OutlineClient, *internal.AccessKeys, error) {
+	region, err := askWorkspace(ctx)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	client, err := newOutlineClient(region)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	accessKeys, err := client.GetAccessKeys(ctx)
+	if err != nil {
+		return "", nil, nil, outlineError(region, err)
+	}
+
+	if len(accessKeys.Keys) == 0 {
+		return "", nil, nil, fmt.Errorf("the access key does not exist in %s", region)
+	}
+
+	accessKeys, err = selectAccessKeys(accessKeys,
+		viper.GetString("export-id"),
+		viper.GetString("export-name"),
+		viper.GetBool("export-all"),
+		"Please select the access key you want to export:")
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	return region, client, accessKeys, nil
+}
+
+// exportServer is the host written into exported keys, the public ip of the EC2 instance unless --server is given.
+func exportServer(ctx context.Context, region string) (string, error) {
+	if server := viper.GetString("export-server"); server != "" {
+		return server, nil
+	}
+
+	instance, err := internal.FindSpecificTagInstance(ctx, *_credential.awsConfig, region)
+	if err != nil {
+		return "", err
+	}
+	if !instance.Existence {
+		return "", fmt.Errorf("there is no running EC2 instance in %s, set the host with --server", region)
+	}
+	return instance.GetPublicIP(), nil
+}
+
+func exportDynamicKey() error {
+	var (
+		outDir   = viper.GetString("export-out-dir")
+		bucket   = viper.GetString("export-bucket")
+		endpoint = viper.GetString("export-endpoint")
+		baseURL  = viper.GetString("export-base-url")
+		err      error
+	)
+
+	if (outDir == "") == (bucket == "") {
+		return fmt.Errorf("one of --out-dir or --bucket is required")
+	}
+
+	if baseURL == "" && bucket != "" {
+		baseURL, err = internal.BucketBaseURL(bucket, endpoint, _credential.awsConfig.Region)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	region, client, accessKeys, err := exportAccessKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	server, err := exportServer(ctx, region)
+	if err != nil {
+		return err
+	}
+
+	// the tokens are saved before anything is published, so a later export
+	// overwrites the same objects and the ssconf:// urls keep working
+	registry, err := loadKeyRegistry(ctx, region, client)
+	if err != nil {
+		return err
+	}
+
+	tokens := make(map[string]string)
+	for _, v := range accessKeys.Keys {
+		if tokens[v.ID], err = registry.DynamicKeyToken(v.ID); err != nil {
+			return err
+		}
+	}
+	if err = registry.Save(internal.KeyRegistryPath(region)); err != nil {
+		return err
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"ID", "Name", "Location", "AccessURL", "Region"})
+
+	for _, v := range accessKeys.Keys {
+		var (
+			token      = tokens[v.ID]
+			dynamicKey = internal.NewDynamicKey(&v, server)
+			location   string
+			ssconfURL  = "-"
+		)
+
+		if bucket != "" {
+			location, err = internal.UploadDynamicKey(ctx, *_credential.awsConfig, dynamicKey, bucket, endpoint, token)
+		} else {
+			location, err = internal.WriteDynamicKey(dynamicKey, outDir, token)
+		}
+		if err != nil {
+			return err
+		}
+
+		if baseURL != "" {
+			ssconfURL, err = internal.SSConfURL(baseURL, token, v.Name)
+			if err != nil {
+				return err
+			}
+		}
+
+		t.AppendRow(table.Row{v.ID, v.Name, location, ssconfURL, region})
+	}
+
+	t.Render()
+
+	if baseURL == "" {
+		notice("Serve %s over https and set --base-url to print the ssconf:// access keys\n", outDir)
+	}
+
+	return nil
+}
+
+func exportAccessURL() error {
+	format := viper.GetString("export-format")
+	if !slices.Contains(internal.ClientFormats, format) {
+		return fmt.Errorf("unsupported format %q (%s)", format, strings.Join(internal.ClientFormats, ", "))
+	}
+
+	ctx := context.Background()
+	region, _, accessKeys, err := exportAccessKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	server, err := exportServer(ctx, region)
+	if err != nil {
+		return err
+	}
+
+	b, err := internal.ClientConfig(accessKeys.Keys, server, format)
+	if err != nil {
+		return err
+	}
+
+	return writeExport(b)
+}
+
+// writeExport writes b to the --file flag with mode 0600, or to stdout without it.
+func writeExport(b []byte) error {
+	file := viper.GetString("export-file")
+	if file == "" {
+		_, err := os.Stdout.Write(b)
+		return err
+	}
+
+	// the export holds the passwords of the access keys
+	if err := os.WriteFile(file, b, 0600); err != nil {
+		return err
+	}
+	congratulation(fmt.Sprintf("Export Success! (%s)\n", file))
+
+	return nil
+}
+
+func exportKeys() error {
+	ctx := context.Background()
+	region, err := askWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	client, err := newOutlineClient(region)
+	if err != nil {
+		return err
+	}
+
+	accessKeys, err := client.GetAccessKeys(ctx)
+	if err != nil {
+		return outlineError(region, err)
+	}
+	accessKeys = accessKeys.Filter(viper.GetString("export-id"), viper.GetString("export-name"))
+
+	registry, err := loadKeyRegistry(ctx, region, client)
+	if err != nil {
+		return err
+	}
+
+	b, err := json.MarshalIndent(internal.NewKeySet(region, accessKeys, registry, time.Now()), "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return writeExport(append(b, '\n'))
+}
+
+var (
+	exportCommand = &cobra.Command{
+		Use:       "export",
+		Short:     "Exporting the outline resources",
+		Long:      "Exporting the outline resources",
+		ValidArgs: []string{"accesskey", "dynamic-key", "keys"},
+		Args:      cobra.MatchAll(internal.WrapArgsError(cobra.MinimumNArgs(1)), cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		Run: func(_ *cobra.Command, args []string) {
+			var (
+				err error
+			)
+
+			switch args[0] {
+			case "accesskey":
+				if err = exportAccessURL(); err != nil {
+					panicRed(err)
+				}
+			case "dynamic-key":
+				if err = exportDynamicKey(); err != nil {
+					panicRed(err)
+				}
+			case "keys":
+				if err = exportKeys(); err != nil {
+					panicRed(err)
+				}
+			}
+		},
+	}
+)
+
+func init() {
+	exportCommand.Flags().StringP("id", "", "", "[optional] id of the access key to export")
+	exportCommand.Flags().StringP("name", "", "", "[optional] name of the access keys to export")
+	exportCommand.Flags().BoolP("all", "", false, "[optional] export every access key of the outline server")
+	exportCommand.Flags().StringP("server", "", "", "[optional] host written into the exported keys (default is the public ip of the EC2 instance)")
+	exportCommand.Flags().StringP("format", "", internal.ClientFormatSIP002, "[accesskey] config format of the client (sip002, clash, singbox, ss-local)")
+	exportCommand.Flags().StringP("file", "", "", "[accesskey, keys] file to write the export (default is stdout)")
+	exportCommand.Flags().StringP("out-dir", "", "", "[dynamic-key] directory to write the dynamic key json files")
+	exportCommand.Flags().StringP("bucket", "", "", "[dynamic-key] bucket to upload the dynamic key json files (s3://bucket/prefix)")
+	exportCommand.Flags().StringP("endpoint", "", "", "[dynamic-key] endpoint of an S3-compatible storage (default is AWS S3)")
+	exportCommand.Flags().StringP("base-url", "", "", "[dynamic-key] https url the dynamic key json files are served from (default is the bucket url)")
+
+	viper.BindPFlag("export-id", exportCommand.Flags().Lookup("id"))
+	viper.BindPFlag("export-name", exportCommand.Flags().Lookup("name"))
+	viper.BindPFlag("export-all", exportCommand.Flags().Lookup("all"))
+	viper.BindPFlag("export-server", exportCommand.Flags().Lookup("server"))
+	viper.BindPFlag("export-format", exportCommand.Flags().Lookup("format"))
+	viper.BindPFlag("export-file", exportCommand.Flags().Lookup("file"))
+	viper.BindPFlag("export-out-dir", exportCommand.Flags().Lookup("out-dir"))
+	viper.BindPFlag("export-bucket", exportCommand.Flags().Lookup("bucket"))
+	viper.BindPFlag("export-endpoint", exportCommand.Flags().Lookup("endpoint"))
+	viper.BindPFlag("export-base-url", exportCommand.Flags().Lookup("base-url"))
+	rootCmd.AddCommand(exportCommand)
+}
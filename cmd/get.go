@@ -2,46 +2,242 @@ package cmd
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"sort"
 
+	"github.com/fatih/color"
 	"github.com/ghdwlsgur/outline-vpn/internal"
 	"github.com/jedib0t/go-pretty/v6/table"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
+// printQRCodes renders the access urls of the region as QR codes with qr, and writes one png per key into qrDir.
+func printQRCodes(region string, accessKeys []internal.AccessKey, qr bool, qrDir string) error {
+	if qrDir != "" {
+		if err := os.MkdirAll(qrDir, 0755); err != nil {
+			return err
+		}
+	}
+
+	for i, v := range accessKeys {
+		if qr {
+			code, err := internal.QRCodeString(v.AccessURL)
+			if err != nil {
+				return err
+			}
+			fmt.Printf("%s\n%s\n", color.HiGreenString("ID: %s %s (%s)", v.ID, v.Name, region), code)
+		}
+
+		if qrDir != "" {
+			path, err := internal.WriteQRCodePNG(&accessKeys[i], region, qrDir)
+			if err != nil {
+				return err
+			}
+			internal.PrintProvisioning("[qr]", v.ID, path)
+		}
+	}
+	return nil
+}
+
+// filterAccessKeys applies the --id, --name, --owner and --tag flags of get.
+func filterAccessKeys(accessKeys *internal.AccessKeys, registry *internal.KeyRegistry) *internal.AccessKeys {
+	accessKeys = accessKeys.Filter(viper.GetString("get-id"), viper.GetString("get-name"))
+	return registry.Filter(accessKeys, viper.GetString("get-owner"), viper.GetString("get-tag"))
+}
+
+func appendAccessKeyRows(t table.Writer, accessKeys *internal.AccessKeys, registry *internal.KeyRegistry, region string) {
+	for _, v := range accessKeys.Keys {
+		metadata := registry.Lookup(v.ID)
+		if metadata == nil {
+			metadata = &internal.KeyMetadata{}
+		}
+		t.AppendRow(table.Row{v.ID, v.Name, v.AccessURL, v.Password, metadata.GetOwner(), metadata.GetTags(), region})
+	}
+}
+
+// getAccessURLAllRegions lists the access keys of every outline server in one table,
+// a server that can't be reached is shown as an error row of its region.
+func getAccessURLAllRegions(ctx context.Context) error {
+	list, err := internal.ValidateOutlineJson(ctx, terraformVersion, _defaultTerraformPath)
+	if err != nil {
+		return err
+	}
+
+	if len(list) == 0 {
+		return fmt.Errorf("there is no outline server, run `outline-vpn apply` first")
+	}
+
+	var (
+		clients = make(map[string]*internal.OutlineClient)
+		failed  = make(map[string]error)
+	)
+	for _, region := range list {
+		client, err := newOutlineClient(region)
+		if err != nil {
+			failed[region] = err
+			continue
+		}
+		clients[region] = client
+	}
+
+	results := internal.GetAccessKeysAllRegions(ctx, clients)
+
+	registries := make(map[string]*internal.KeyRegistry)
+	for i, result := range results {
+		if result.Err != nil {
+			continue
+		}
+
+		registry, err := loadKeyRegistry(ctx, result.Region, clients[result.Region])
+		if err == nil {
+			err = reconcileKeyRegistry(result.Region, registry, result.AccessKeys)
+		}
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		registries[result.Region] = registry
+	}
+
+	for region, err := range failed {
+		results = append(results, internal.RegionAccessKeys{Region: region, Err: err})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Region < results[j].Region
+	})
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"ID", "Name", "AccessURL", "Password", "Owner", "Tags", "Region"})
+
+	var (
+		accessKeys = make([]internal.RegionAccessKeys, 0)
+		errCount   int
+	)
+	for _, result := range results {
+		if result.Err != nil {
+			errCount++
+			t.AppendRow(table.Row{"-", "-", color.RedString("%s", result.Err), "-", "-", "-", result.Region})
+			continue
+		}
+
+		filtered := filterAccessKeys(result.AccessKeys, registries[result.Region])
+		appendAccessKeyRows(t, filtered, registries[result.Region], result.Region)
+		accessKeys = append(accessKeys, internal.RegionAccessKeys{Region: result.Region, AccessKeys: filtered})
+	}
+
+	t.Render()
+
+	for _, v := range accessKeys {
+		if err = printQRCodes(v.Region, v.AccessKeys.Keys, viper.GetBool("get-qr"), viper.GetString("get-qr-dir")); err != nil {
+			return err
+		}
+	}
+
+	if errCount > 0 {
+		return fmt.Errorf("%d of %d outline servers could not be listed", errCount, len(results))
+	}
+	return nil
+}
+
 func getAccessURL() error {
 
 	ctx := context.Background()
-	list, err := internal.ValidateOutlineJson(ctx, terraformVersion, _defaultTerraformPath)
+	if viper.GetBool("get-all-regions") {
+		return getAccessURLAllRegions(ctx)
+	}
+
+	answer, err := askWorkspace(ctx)
 	if err != nil {
 		return err
 	}
 
-	answer, err := internal.AskPromptOptionList("Choose a Workspace (Region):", list, 10)
+	client, err := newOutlineClient(answer)
 	if err != nil {
 		return err
 	}
 
-	accessKeys, err := internal.GetAccessKeys(answer)
+	accessKeys, err := client.GetAccessKeys(ctx)
 	if err != nil {
+		return outlineError(answer, err)
+	}
+
+	registry, err := loadKeyRegistry(ctx, answer, client)
+	if err != nil {
+		return err
+	}
+	if err = reconcileKeyRegistry(answer, registry, accessKeys); err != nil {
 		return err
 	}
+	accessKeys = filterAccessKeys(accessKeys, registry)
 
 	t := table.NewWriter()
 	t.SetOutputMirror(os.Stdout)
 
 	if len(accessKeys.Keys) > 0 {
-		t.AppendHeader(table.Row{"ID", "AccessURL", "Password", "Region"})
-		for _, v := range accessKeys.Keys {
-			t.AppendRow(table.Row{v.ID, v.AccessURL, v.Password, answer})
-		}
+		t.AppendHeader(table.Row{"ID", "Name", "AccessURL", "Password", "Owner", "Tags", "Region"})
+		appendAccessKeyRows(t, accessKeys, registry, answer)
 	} else {
 		fmt.Println("The access key does not exist")
 	}
 
 	t.Render()
 
+	return printQRCodes(answer, accessKeys.Keys, viper.GetBool("get-qr"), viper.GetString("get-qr-dir"))
+}
+
+func getMetrics() error {
+
+	ctx := context.Background()
+	answer, err := askWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	client, err := newOutlineClient(answer)
+	if err != nil {
+		return err
+	}
+
+	accessKeys, err := client.GetAccessKeys(ctx)
+	if err != nil {
+		return outlineError(answer, err)
+	}
+
+	metrics, err := client.GetTransferMetrics(ctx)
+	if err != nil {
+		return outlineError(answer, err)
+	}
+
+	usages := internal.JoinKeyUsage(accessKeys, metrics)
+	if err = internal.SortKeyUsage(usages, viper.GetString("get-sort")); err != nil {
+		return err
+	}
+
+	switch viper.GetString("get-output") {
+	case "json":
+		b, err := json.MarshalIndent(usages, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+	case "table":
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+
+		t.AppendHeader(table.Row{"ID", "Name", "Bytes Used", "Data Limit", "% of Limit", "Region"})
+		for _, v := range usages {
+			t.AppendRow(table.Row{v.ID, v.Name, internal.FormatDataSize(v.BytesTransferred), v.GetDataLimit(), v.GetPercentOfLimit(), answer})
+		}
+
+		t.Render()
+	default:
+		return fmt.Errorf("invalid output %q (table, json)", viper.GetString("get-output"))
+	}
+
 	return nil
 }
 
@@ -50,7 +246,7 @@ var (
 		Use:       "get",
 		Short:     "Retrieving the outline resources",
 		Long:      "Retrieving the outline resources",
-		ValidArgs: []string{"accesskey"},
+		ValidArgs: []string{"accesskey", "metrics"},
 		Args:      cobra.MatchAll(internal.WrapArgsError(cobra.MinimumNArgs(1)), cobra.ExactArgs(1), cobra.OnlyValidArgs),
 		Run: func(_ *cobra.Command, args []string) {
 			var (
@@ -59,7 +255,11 @@ var (
 
 			switch args[0] {
 			case "accesskey":
-				if getAccessURL(); err != nil {
+				if err = getAccessURL(); err != nil {
+					panicRed(err)
+				}
+			case "metrics":
+				if err = getMetrics(); err != nil {
 					panicRed(err)
 				}
 			}
@@ -69,5 +269,24 @@ var (
 )
 
 func init() {
+	getCommand.Flags().StringP("id", "", "", "[optional] only show the access key with this id")
+	getCommand.Flags().StringP("name", "", "", "[optional] only show the access keys with this name")
+	getCommand.Flags().StringP("owner", "", "", "[optional] only show the access keys of this owner")
+	getCommand.Flags().StringP("tag", "", "", "[optional] only show the access keys with this tag")
+	getCommand.Flags().BoolP("all-regions", "", false, "[optional] list the access keys of the outline servers in every region")
+	getCommand.Flags().BoolP("qr", "", false, "[optional] print the access urls as QR codes")
+	getCommand.Flags().StringP("qr-dir", "", "", "[optional] directory to write one QR code png per access key")
+	getCommand.Flags().StringP("sort", "", "id", "[optional] sort metrics by id, name, bytes or percent")
+	getCommand.Flags().StringP("output", "o", "table", "[optional] output format of metrics, table or json")
+
+	viper.BindPFlag("get-id", getCommand.Flags().Lookup("id"))
+	viper.BindPFlag("get-name", getCommand.Flags().Lookup("name"))
+	viper.BindPFlag("get-owner", getCommand.Flags().Lookup("owner"))
+	viper.BindPFlag("get-tag", getCommand.Flags().Lookup("tag"))
+	viper.BindPFlag("get-all-regions", getCommand.Flags().Lookup("all-regions"))
+	viper.BindPFlag("get-qr", getCommand.Flags().Lookup("qr"))
+	viper.BindPFlag("get-qr-dir", getCommand.Flags().Lookup("qr-dir"))
+	viper.BindPFlag("get-sort", getCommand.Flags().Lookup("sort"))
+	viper.BindPFlag("get-output", getCommand.Flags().Lookup("output"))
 	rootCmd.AddCommand(getCommand)
 }
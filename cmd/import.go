@@ -0,0 +1,94 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/fatih/color"
+	"github.com/ghdwlsgur/outline-vpn/internal"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+func importKeys(path string) error {
+	keySet, err := internal.ReadKeySet(path)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	region, err := askWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	client, err := newOutlineClient(region)
+	if err != nil {
+		return err
+	}
+
+	existing, err := client.GetAccessKeys(ctx)
+	if err != nil {
+		return outlineError(region, err)
+	}
+
+	registry, err := loadKeyRegistry(ctx, region, client)
+	if err != nil {
+		return err
+	}
+
+	results := internal.ImportKeySet(ctx, client, existing, registry, keySet)
+	if err = registry.Save(internal.KeyRegistryPath(region)); err != nil {
+		return err
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+
+	failed := 0
+	t.AppendHeader(table.Row{"ID", "Name", "Status", "Region"})
+	for _, v := range results {
+		status := v.Status
+		if v.Status == internal.ImportFailed {
+			failed++
+			status = color.RedString("%s (%s)", v.Status, v.Error)
+		}
+		t.AppendRow(table.Row{v.ID, v.Name, status, region})
+	}
+
+	t.Render()
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d access keys failed to import", failed, len(results))
+	}
+	congratulation(fmt.Sprintf("Import Success! (%s -> %s)\n", keySet.Region, region))
+	notice("The access keys keep their secrets, clients only need the new host unless the keys use a hostname (outline-vpn server set-hostname)\n")
+
+	return nil
+}
+
+var (
+	importCommand = &cobra.Command{
+		Use:   "import",
+		Short: "Importing the outline resources",
+		Long:  "Importing the outline resources",
+	}
+
+	importKeysCommand = &cobra.Command{
+		Use:   "keys [file]",
+		Short: "Recreate the access keys of `outline-vpn export keys` with the same secrets",
+		Long:  "Recreate the access keys of `outline-vpn export keys` with the same id, secret, method, port, name and data limit, e.g. on a rebuilt server",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			if err := importKeys(args[0]); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+)
+
+func init() {
+	importCommand.AddCommand(importKeysCommand)
+	rootCmd.AddCommand(importCommand)
+}
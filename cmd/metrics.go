@@ -0,0 +1,197 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/ghdwlsgur/outline-vpn/internal"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func usageStorePath() string {
+	return filepath.Join(_credential.homePath, "usage.db")
+}
+
+// snapshotRegion reads the usage of the last 30 days of every access key in the region.
+func snapshotRegion(ctx context.Context, region string, now time.Time) (*internal.UsageSnapshot, error) {
+	client, err := newOutlineClient(region)
+	if err != nil {
+		return nil, err
+	}
+
+	accessKeys, err := client.GetAccessKeys(ctx)
+	if err != nil {
+		return nil, outlineError(region, err)
+	}
+
+	metrics, err := client.GetTransferMetrics(ctx)
+	if err != nil {
+		return nil, outlineError(region, err)
+	}
+
+	server, err := client.GetServer(ctx)
+	if err != nil {
+		return nil, outlineError(region, err)
+	}
+
+	return internal.NewUsageSnapshot(region, server.ServerID, now, internal.JoinKeyUsage(accessKeys, metrics)), nil
+}
+
+func snapshotMetrics() error {
+	ctx := context.Background()
+	list, err := internal.ValidateOutlineJson(ctx, terraformVersion, _defaultTerraformPath)
+	if err != nil {
+		return err
+	}
+
+	if len(list) == 0 {
+		return fmt.Errorf("there is no outline server, run `outline-vpn apply` first")
+	}
+
+	store, err := internal.OpenUsageStore(usageStorePath())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	var (
+		now      = time.Now()
+		errCount int
+	)
+	for _, region := range list {
+		snapshot, err := snapshotRegion(ctx, region, now)
+		if err == nil {
+			err = store.Add(snapshot)
+		}
+		if err != nil {
+			errCount++
+			fmt.Println(color.RedString("[failed] %s: %s", region, err))
+			continue
+		}
+		internal.PrintProvisioning("[snapshot]", region, fmt.Sprintf("%d access keys", len(snapshot.Records)))
+	}
+
+	if errCount > 0 {
+		return fmt.Errorf("%d of %d outline servers could not be recorded", errCount, len(list))
+	}
+	congratulation(fmt.Sprintf("Snapshot Success! (%s)\n", usageStorePath()))
+
+	return nil
+}
+
+func reportMetrics() error {
+	since, err := internal.ParseDuration(viper.GetString("metrics-since"))
+	if err != nil {
+		return err
+	}
+
+	store, err := internal.OpenUsageStore(usageStorePath())
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+
+	snapshots, err := store.Snapshots()
+	if err != nil {
+		return err
+	}
+
+	report, err := internal.UsageReport(snapshots, time.Now().Add(-since),
+		viper.GetString("metrics-period"), viper.GetString("metrics-group"))
+	if err != nil {
+		return err
+	}
+
+	switch viper.GetString("metrics-output") {
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		w.Write([]string{"period", "region", "server", "id", "name", "bytes"})
+		for _, v := range report {
+			w.Write([]string{v.Period, v.Region, v.ServerID, v.ID, v.Name, strconv.Itoa(v.Bytes)})
+		}
+		w.Flush()
+		return w.Error()
+	case "table":
+		if len(report) == 0 {
+			fmt.Println("There is no usage in the period, record snapshots with `outline-vpn metrics snapshot`")
+			return nil
+		}
+
+		t := table.NewWriter()
+		t.SetOutputMirror(os.Stdout)
+
+		t.AppendHeader(table.Row{"Period", "Region", "Server", "ID", "Name", "Bytes Used"})
+		for _, v := range report {
+			serverID, id, name := v.ServerID, v.ID, v.Name
+			if serverID == "" {
+				serverID = "-"
+			}
+			if id == "" {
+				id, name = "-", "-"
+			}
+			t.AppendRow(table.Row{v.Period, v.Region, serverID, id, name, internal.FormatDataSize(v.Bytes)})
+		}
+
+		t.Render()
+	default:
+		return fmt.Errorf("invalid output %q (table, csv)", viper.GetString("metrics-output"))
+	}
+
+	return nil
+}
+
+var (
+	metricsCommand = &cobra.Command{
+		Use:   "metrics",
+		Short: "Recording the data usage history of the outline servers",
+		Long:  "Recording the data usage history of the outline servers. The outline server only reports the sums of the last 30 days, so snapshots are kept in ~/.outline-vpn/usage.db.",
+	}
+
+	metricsSnapshotCommand = &cobra.Command{
+		Use:   "snapshot",
+		Short: "Record the usage of every access key in every region",
+		Long:  "Record the usage of every access key in every region, e.g. daily from cron",
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			if err := snapshotMetrics(); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+
+	metricsReportCommand = &cobra.Command{
+		Use:   "report",
+		Short: "Show the data used per period from the recorded snapshots",
+		Long:  "Show the data used per period from the recorded snapshots",
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			if err := reportMetrics(); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+)
+
+func init() {
+	metricsReportCommand.Flags().StringP("since", "", "30d", "[optional] how far back the report goes (e.g. 30d, 12h)")
+	metricsReportCommand.Flags().StringP("period", "", internal.UsagePeriodDaily, "[optional] daily or monthly usage")
+	metricsReportCommand.Flags().StringP("group", "", internal.UsageGroupKey, "[optional] usage per key or per region")
+	metricsReportCommand.Flags().StringP("output", "o", "table", "[optional] output format, table or csv")
+
+	viper.BindPFlag("metrics-since", metricsReportCommand.Flags().Lookup("since"))
+	viper.BindPFlag("metrics-period", metricsReportCommand.Flags().Lookup("period"))
+	viper.BindPFlag("metrics-group", metricsReportCommand.Flags().Lookup("group"))
+	viper.BindPFlag("metrics-output", metricsReportCommand.Flags().Lookup("output"))
+
+	metricsCommand.AddCommand(metricsSnapshotCommand)
+	metricsCommand.AddCommand(metricsReportCommand)
+	rootCmd.AddCommand(metricsCommand)
+}
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/ghdwlsgur/outline-vpn/internal"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// moduleSourcePath is the configuration of where main.tf takes the terraform module from.
+func moduleSourcePath() string {
+	return filepath.Join(_credential.homePath, "module.json")
+}
+
+func showModuleSource() error {
+	module, err := internal.LoadModuleSource(moduleSourcePath())
+	if err != nil {
+		return err
+	}
+
+	source, version := module.Attributes()
+	if module.Type == internal.ModuleEmbedded {
+		version = internal.EmbeddedModuleVersion()
+	}
+	if version == "" {
+		version = "-"
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Type", "Source", "Version", "Embedded Module"})
+	t.AppendRow(table.Row{module.Type, source, version, internal.HasEmbeddedModule()})
+	t.Render()
+	return nil
+}
+
+func setModuleSource(moduleType string) error {
+	module := &internal.ModuleSource{
+		Type:    moduleType,
+		Source:  viper.GetString("module-source"),
+		Version: viper.GetString("module-version"),
+	}
+	if moduleType == internal.ModuleEmbedded && (module.Source != "" || module.Version != "") {
+		return fmt.Errorf("the embedded module takes neither --source nor --version")
+	}
+
+	if err := module.Save(moduleSourcePath()); err != nil {
+		return err
+	}
+
+	congratulation(fmt.Sprintf("Module Source Update Success! (%s)\n", moduleType))
+	notice("The next `outline-vpn apply` of a workspace writes the new source and runs terraform init\n")
+	return nil
+}
+
+var (
+	moduleCommand = &cobra.Command{
+		Use:   "module",
+		Short: "Managing where terraform takes the outline-vpn module from",
+		Long:  "Managing where terraform takes the outline-vpn module from, the module embedded in the binary (default), a registry or a git repository",
+	}
+
+	moduleShowCommand = &cobra.Command{
+		Use:   "show",
+		Short: "Show the source of the terraform module",
+		Long:  "Show the source of the terraform module",
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			if err := showModuleSource(); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+
+	moduleSetCommand = &cobra.Command{
+		Use:       "set",
+		Short:     "Set the source of the terraform module (embedded, registry, git)",
+		Long:      "Set the source of the terraform module (embedded, registry, git), e.g. a private registry, a fork in git or a pinned version",
+		ValidArgs: []string{internal.ModuleEmbedded, internal.ModuleRegistry, internal.ModuleGit},
+		Args:      cobra.MatchAll(internal.WrapArgsError(cobra.MinimumNArgs(1)), cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		Run: func(_ *cobra.Command, args []string) {
+			if err := setModuleSource(args[0]); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+)
+
+func init() {
+	moduleSetCommand.Flags().StringP("source", "", "", "[optional] registry address (default ghdwlsgur/outline-vpn/ghdwlsgur) or url of the git repository")
+	moduleSetCommand.Flags().StringP("version", "", "", "[optional] version of the registry module (default 1.0.0) or ref of the git repository")
+
+	viper.BindPFlag("module-source", moduleSetCommand.Flags().Lookup("source"))
+	viper.BindPFlag("module-version", moduleSetCommand.Flags().Lookup("version"))
+
+	moduleCommand.AddCommand(moduleShowCommand)
+	moduleCommand.AddCommand(moduleSetCommand)
+	rootCmd.AddCommand(moduleCommand)
+}
@@ -0,0 +1,144 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/ghdwlsgur/outline-vpn/internal"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// pruneRegion deletes the expired access keys of the outline server in the
+// region and appends a row per key to t. It returns how many deletions failed.
+func pruneRegion(ctx context.Context, region string, now time.Time, dryRun bool, t table.Writer) (int, error) {
+	client, err := newOutlineClient(region)
+	if err != nil {
+		return 0, err
+	}
+
+	registry, err := loadKeyRegistry(ctx, region, client)
+	if err != nil {
+		return 0, err
+	}
+
+	accessKeys, err := client.GetAccessKeys(ctx)
+	if err != nil {
+		return 0, outlineError(region, err)
+	}
+
+	if !dryRun {
+		registry.Reconcile(accessKeys)
+	}
+
+	failed := 0
+	for _, id := range registry.Expired(now) {
+		expiresAt := registry.Get(id).ExpiresAt.Format(time.RFC3339)
+
+		// deleted by other means, only the metadata is left
+		found := accessKeys.Filter(id, "")
+		if len(found.Keys) == 0 {
+			continue
+		}
+
+		name := found.Keys[0].Name
+		switch {
+		case dryRun:
+			t.AppendRow(table.Row{id, name, expiresAt, color.YellowString("would delete"), region})
+		default:
+			err := client.DeleteAccessKey(ctx, id)
+			if err != nil && !errors.Is(err, internal.ErrOutlineNotFound) {
+				failed++
+				t.AppendRow(table.Row{id, name, expiresAt, color.RedString("failed: %s", err), region})
+				continue
+			}
+			registry.Delete(id)
+			t.AppendRow(table.Row{id, name, expiresAt, color.GreenString("deleted"), region})
+		}
+	}
+
+	if dryRun {
+		return failed, nil
+	}
+	return failed, registry.Save(internal.KeyRegistryPath(region))
+}
+
+func pruneAccessURL() error {
+	dryRun := viper.GetBool("prune-dry-run")
+
+	ctx := context.Background()
+	list, err := internal.ValidateOutlineJson(ctx, terraformVersion, _defaultTerraformPath)
+	if err != nil {
+		return err
+	}
+
+	if len(list) == 0 {
+		return fmt.Errorf("there is no outline server, run `outline-vpn apply` first")
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"ID", "Name", "Expired At", "Status", "Region"})
+
+	var (
+		now      = time.Now()
+		errCount int
+	)
+	for _, region := range list {
+		failed, err := pruneRegion(ctx, region, now, dryRun, t)
+		errCount += failed
+		if err != nil {
+			errCount++
+			fmt.Println(color.RedString("[failed] %s: %s", region, err))
+		}
+	}
+
+	if t.Length() == 0 {
+		fmt.Println("There is no expired access key")
+	} else {
+		t.Render()
+	}
+
+	if errCount > 0 {
+		return fmt.Errorf("%d expired access keys or outline servers could not be pruned", errCount)
+	}
+	if !dryRun && t.Length() > 0 {
+		congratulation("Prune Success!\n")
+	}
+
+	return nil
+}
+
+var (
+	pruneCommand = &cobra.Command{
+		Use:       "prune",
+		Short:     "Deleting the expired outline resources",
+		Long:      "Deleting the expired outline resources of every workspace, e.g. the access keys created with --ttl",
+		ValidArgs: []string{"accesskey"},
+		Args:      cobra.MatchAll(internal.WrapArgsError(cobra.MinimumNArgs(1)), cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		Run: func(_ *cobra.Command, args []string) {
+			var (
+				err error
+			)
+
+			switch args[0] {
+			case "accesskey":
+				if err = pruneAccessURL(); err != nil {
+					panicRed(err)
+				}
+			}
+		},
+	}
+)
+
+func init() {
+	pruneCommand.Flags().BoolP("dry-run", "", false, "[optional] only show the access keys that would be deleted")
+
+	viper.BindPFlag("prune-dry-run", pruneCommand.Flags().Lookup("dry-run"))
+	rootCmd.AddCommand(pruneCommand)
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghdwlsgur/outline-vpn/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// renderVariables reads the terraform variables from --var-file (terraform.tfvars.json
+// of apply by default) and lets the flags override them.
+func renderVariables() (*TerraformVarsJSON, error) {
+	vars := &TerraformVarsJSON{}
+
+	varFile := viper.GetString("render-var-file")
+	if varFile == "" {
+		varFile = _defaultTerraformVars
+	}
+
+	b, err := os.ReadFile(varFile)
+	switch {
+	case err == nil:
+		if err = json.Unmarshal(b, vars); err != nil {
+			return nil, fmt.Errorf("%s: %w", varFile, err)
+		}
+	case errors.Is(err, os.ErrNotExist) && !viper.IsSet("render-var-file"):
+	default:
+		return nil, err
+	}
+
+	if region := viper.GetString("region"); region != "" && region != vars.AWSRegion {
+		// the zone, image and type of the file belong to its region
+		vars = &TerraformVarsJSON{AWSRegion: region}
+	}
+	if v := viper.GetString("render-az"); v != "" {
+		vars.AvailabilityZone = v
+	}
+	if v := viper.GetString("render-ami"); v != "" {
+		vars.EC2Ami = v
+	}
+	if v := viper.GetString("render-instance-type"); v != "" {
+		vars.InstanceType = v
+	}
+
+	missing := make([]string, 0)
+	for _, v := range [][2]string{
+		{"--region", vars.AWSRegion},
+		{"--az", vars.AvailabilityZone},
+		{"--ami", vars.EC2Ami},
+		{"--instance-type", vars.InstanceType},
+	} {
+		if v[1] == "" {
+			missing = append(missing, v[0])
+		}
+	}
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%s required without a terraform.tfvars.json that has them", strings.Join(missing, ", "))
+	}
+
+	return vars, nil
+}
+
+func renderTf() error {
+	out := viper.GetString("render-out")
+
+	vars, err := renderVariables()
+	if err != nil {
+		return err
+	}
+
+	backend, err := internal.LoadBackend(backendPath())
+	if err != nil {
+		return err
+	}
+
+	module, err := internal.LoadModuleSource(moduleSourcePath())
+	if err != nil {
+		return err
+	}
+
+	names := []string{"main.tf", "provider.tf", "output.tf", "key.tf"}
+	if !backend.IsLocal() {
+		names = append(names, "backend.tf")
+	}
+	if module.Type == internal.ModuleEmbedded {
+		names = append(names, "modules")
+	}
+
+	// the directory may be a repository of the user, only --force writes over its files
+	if !viper.GetBool("render-force") {
+		for _, name := range names {
+			if _, err := os.Stat(filepath.Join(out, name)); err == nil {
+				return fmt.Errorf("%s already exists, use --force to overwrite it", filepath.Join(out, name))
+			}
+		}
+	}
+
+	if err = os.MkdirAll(out, 0755); err != nil {
+		return err
+	}
+
+	if err = internal.RenderTf(out, vars.AWSRegion, vars.EC2Ami, vars.InstanceType, vars.AvailabilityZone, backend, module); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		internal.PrintProvisioning("[render]", name+":", filepath.Join(out, name))
+	}
+	if _, err := os.Stat(filepath.Join(out, "backend.tf")); err == nil && backend.IsLocal() {
+		notice("%s is left as it is, remove it to keep the state local\n", filepath.Join(out, "backend.tf"))
+	}
+	return nil
+}
+
+var (
+	renderCommand = &cobra.Command{
+		Use:   "render",
+		Short: "Write the terraform files of apply without calling AWS or Terraform",
+		Long:  "Write main.tf, provider.tf, backend.tf, output.tf, key.tf and the embedded module of apply from the flags or terraform.tfvars.json, to review them or run them with your own terraform pipeline",
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			if err := renderTf(); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+)
+
+func init() {
+	renderCommand.Flags().StringP("out", "o", "", "[required] directory to write the terraform files to")
+	renderCommand.Flags().StringP("var-file", "", "", "[optional] terraform.tfvars.json to read the variables from (default is the one of apply)")
+	renderCommand.Flags().StringP("az", "", "", "[optional] availability zone of the EC2 instance, e.g. us-east-1a")
+	renderCommand.Flags().StringP("ami", "", "", "[optional] id of the Amazon Machine Image of the EC2 instance")
+	renderCommand.Flags().StringP("instance-type", "", "", "[optional] instance type of the EC2 instance, e.g. t2.micro")
+	renderCommand.Flags().BoolP("force", "f", false, "[optional] overwrite the terraform files that already exist in --out")
+	renderCommand.MarkFlagRequired("out")
+
+	viper.BindPFlag("render-out", renderCommand.Flags().Lookup("out"))
+	viper.BindPFlag("render-var-file", renderCommand.Flags().Lookup("var-file"))
+	viper.BindPFlag("render-az", renderCommand.Flags().Lookup("az"))
+	viper.BindPFlag("render-ami", renderCommand.Flags().Lookup("ami"))
+	viper.BindPFlag("render-instance-type", renderCommand.Flags().Lookup("instance-type"))
+	viper.BindPFlag("render-force", renderCommand.Flags().Lookup("force"))
+	rootCmd.AddCommand(renderCommand)
+}
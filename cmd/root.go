@@ -8,6 +8,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/config"
@@ -80,6 +81,106 @@ func panicRed(err error) {
 	os.Exit(1)
 }
 
+// outlineError turns an error of the outline management api into a message
+// that tells the user what to check on the server in the given workspace.
+func outlineError(region string, err error) error {
+	switch {
+	case errors.Is(err, internal.ErrOutlineUnreachable):
+		return fmt.Errorf("the outline server in %s is unreachable, check that the EC2 instance is running\n%s", region, err)
+	case errors.Is(err, internal.ErrOutlineCertMismatch):
+		return fmt.Errorf("the outline server in %s presented an unexpected certificate, check outline.json in the workspace\n%s", region, err)
+	case errors.Is(err, internal.ErrOutlineNotFound):
+		return fmt.Errorf("the requested resource does not exist on the outline server in %s\n%s", region, err)
+	case errors.Is(err, internal.ErrOutlineLimitExceeded):
+		return fmt.Errorf("the outline server in %s rejected the request because a limit was exceeded\n%s", region, err)
+	}
+	return err
+}
+
+// newOutlineClient connects to the outline server of the workspace, the
+// calls honour the --timeout and --verbose flags.
+func newOutlineClient(region string) (*internal.OutlineClient, error) {
+	opts := []internal.OutlineClientOption{internal.WithTimeout(viper.GetDuration("timeout"))}
+	if viper.GetBool("verbose") {
+		opts = append(opts, internal.WithVerbose(os.Stderr))
+	}
+
+	return internal.NewOutlineClientFromRegion(region, opts...)
+}
+
+// loadKeyRegistry reads the local metadata of the access keys of the outline server in the workspace.
+func loadKeyRegistry(ctx context.Context, region string, client *internal.OutlineClient) (*internal.KeyRegistry, error) {
+	server, err := client.GetServer(ctx)
+	if err != nil {
+		return nil, outlineError(region, err)
+	}
+
+	return internal.LoadKeyRegistry(internal.KeyRegistryPath(region), server.ServerID)
+}
+
+// reconcileKeyRegistry drops the metadata of the access keys deleted on the
+// server and saves the registry when anything was dropped.
+func reconcileKeyRegistry(region string, registry *internal.KeyRegistry, accessKeys *internal.AccessKeys) error {
+	if removed := registry.Reconcile(accessKeys); len(removed) == 0 {
+		return nil
+	}
+	return registry.Save(internal.KeyRegistryPath(region))
+}
+
+// setKeyMetadata copies the --owner, --email, --tag and --notes flags that
+// were given to the command into metadata and tells whether there was any.
+// prefix is the command of the flags bound to viper (e.g. create).
+func setKeyMetadata(prefix string, metadata *internal.KeyMetadata) bool {
+	changed := false
+
+	if viper.IsSet(prefix + "-owner") {
+		metadata.Owner = viper.GetString(prefix + "-owner")
+		changed = true
+	}
+	if viper.IsSet(prefix + "-email") {
+		metadata.Email = viper.GetString(prefix + "-email")
+		changed = true
+	}
+	if viper.IsSet(prefix + "-tag") {
+		metadata.Tags = viper.GetStringSlice(prefix + "-tag")
+		changed = true
+	}
+	if viper.IsSet(prefix + "-notes") {
+		metadata.Notes = viper.GetString(prefix + "-notes")
+		changed = true
+	}
+	return changed
+}
+
+// askWorkspace lets the user choose one of the workspaces (regions) that
+// have an outline.json, i.e. a provisioned outline server. The --region flag
+// skips the prompt.
+func askWorkspace(ctx context.Context) (string, error) {
+	list, err := internal.ValidateOutlineJson(ctx, terraformVersion, _defaultTerraformPath)
+	if err != nil {
+		return "", err
+	}
+
+	if len(list) == 0 {
+		return "", fmt.Errorf("there is no outline server, run `outline-vpn apply` first")
+	}
+
+	if region := viper.GetString("region"); region != "" {
+		for _, workspace := range list {
+			if workspace == region {
+				return region, nil
+			}
+		}
+		return "", fmt.Errorf("there is no outline server in %s", region)
+	}
+
+	if !internal.IsTerminal() {
+		return "", fmt.Errorf("--region is required when stdin is not a terminal")
+	}
+
+	return internal.AskPromptOptionList("Choose a Workspace (Region):", list, 10)
+}
+
 func workingDirInit() {
 	if _, err := os.Stat(_defaultTerraformPath); errors.Is(err, os.ErrNotExist) {
 		err := os.MkdirAll(_defaultTerraformPath, 0755)
@@ -106,7 +207,7 @@ func findSharedCredFile() {
 	sharedCredFile := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
 	if sharedCredFile == "" {
 		if _, err := os.Stat(_credentialWithMFA); !os.IsNotExist(err) {
-			color.Yellow("[Use] outline-vpn default mfa credential file %s", _credentialWithMFA)
+			fmt.Fprintln(os.Stderr, color.YellowString("[Use] outline-vpn default mfa credential file %s", _credentialWithMFA))
 			os.Setenv("AWS_SHARED_CREDENTIALS_FILE", _credentialWithMFA)
 			sharedCredFile = _credentialWithMFA
 		}
@@ -126,7 +227,7 @@ func findSharedCredFile() {
 
 		if err != nil {
 			if cred.Expired() || cred.AccessKeyID == "" || cred.SecretAccessKey == "" {
-				color.Yellow("[Expire] outline-vpn default mfa credential file %s", sharedCredFile)
+				fmt.Fprintln(os.Stderr, color.YellowString("[Expire] outline-vpn default mfa credential file %s", sharedCredFile))
 				os.Unsetenv("AWS_SHARED_CREDENTIALS_FILE")
 			} else {
 				_credential.awsConfig = &awsConfig
@@ -250,7 +351,7 @@ func libPrerequisite(libList []string) {
 			panicRed(fmt.Errorf("⚠️  %s is not installed\n[required] jq, rsync and terraform must be installed as prerequisites", lib))
 		}
 	}
-	fmt.Println()
+	fmt.Fprintln(os.Stderr)
 }
 
 func PrintFunc(field, value string) {
@@ -261,7 +362,7 @@ func PrintFunc(field, value string) {
 	}
 }
 
-func setUpPlugin() {
+func setUpHome() {
 	home, err := homedir.Dir()
 	if err != nil {
 		panicRed(internal.WrapError(err))
@@ -273,6 +374,10 @@ func setUpPlugin() {
 			panicRed(internal.WrapError(err))
 		}
 	}
+}
+
+func setUpPlugin() {
+	setUpHome()
 
 	plugin, err := internal.GetSSMPlugin()
 	if err != nil {
@@ -282,7 +387,7 @@ func setUpPlugin() {
 	_credential.ssmPluginPath = filepath.Join(_credential.homePath,
 		internal.GetSSMPluginName())
 	if info, err := os.Stat(_credential.ssmPluginPath); os.IsNotExist(err) {
-		color.Green("[create] aws ssm plugin")
+		fmt.Fprintln(os.Stderr, color.GreenString("[create] aws ssm plugin"))
 		if err := os.WriteFile(_credential.ssmPluginPath, plugin, 0755); err != nil {
 			panicRed(internal.WrapError(err))
 		}
@@ -290,7 +395,7 @@ func setUpPlugin() {
 		panicRed(internal.WrapError(err))
 	} else {
 		if int(info.Size()) != len(plugin) {
-			color.Green("[update] aws ssm plugin")
+			fmt.Fprintln(os.Stderr, color.GreenString("[update] aws ssm plugin"))
 			if err := os.WriteFile(_credential.ssmPluginPath, plugin, 0755); err != nil {
 				panicRed(internal.WrapError(err))
 			}
@@ -298,14 +403,11 @@ func setUpPlugin() {
 	}
 }
 
+// initConfig writes its notices to stderr, stdout is the output of the command
+// (export, get metrics -o json, metrics report -o csv) and may be redirected.
 func initConfig() {
 
 	_credential = &Credential{}
-	workingDirInit()
-
-	findProfile()
-	findSharedCredFile()
-	setUpPlugin()
 
 	args := os.Args[1:]
 	subcmd, _, err := rootCmd.Find(args)
@@ -313,6 +415,18 @@ func initConfig() {
 		panicRed(internal.WrapError(err))
 	}
 
+	// render and module only write files, they need neither aws nor terraform
+	if subcmd == renderCommand || subcmd.Parent() == moduleCommand {
+		setUpHome()
+		return
+	}
+
+	workingDirInit()
+
+	findProfile()
+	findSharedCredFile()
+	setUpPlugin()
+
 	switch subcmd.Use {
 	case "mfa":
 		if _credential.awsConfig != nil {
@@ -344,9 +458,13 @@ func init() {
 
 	rootCmd.PersistentFlags().StringP("profile", "p", "", `[optional] if you having multiple aws profiles, it is one of profiles (default is AWS_PROFILE environment variable or default)`)
 	rootCmd.PersistentFlags().StringP("region", "r", "", `[optional] it is region in AWS would like to do something`)
+	rootCmd.PersistentFlags().DurationP("timeout", "", 2*time.Minute, `[optional] how long a call to the outline server may take, retries included`)
+	rootCmd.PersistentFlags().BoolP("verbose", "", false, `[optional] report every attempt of the calls to the outline server`)
 
 	rootCmd.InitDefaultVersionFlag()
 
 	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
 	viper.BindPFlag("region", rootCmd.PersistentFlags().Lookup("region"))
+	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+	viper.BindPFlag("verbose", rootCmd.PersistentFlags().Lookup("verbose"))
 }
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/ghdwlsgur/outline-vpn/internal"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// deleteDueRotations deletes the rotated access keys whose grace period has passed.
+// A key that can't be deleted stays pending for the next run, it's only
+// dropped when its outline server is gone: the workspace was destroyed, or
+// the server in the workspace is another one that reuses the ids.
+func deleteDueRotations(ctx context.Context, state *internal.RotationState) {
+	for _, v := range state.Due(time.Now()) {
+		client, err := newOutlineClient(v.Region)
+		if err != nil {
+			// destroy deletes the workspace, the key went with the outline server
+			if _, statErr := os.Stat(internal.ReturnTerraformPath(v.Region)); errors.Is(statErr, os.ErrNotExist) {
+				state.Remove(v.Region, v.ID)
+				notice("Drop Rotated Key (%s, ID: %s), the workspace of %s was destroyed\n", v.Name, v.ID, v.Region)
+				continue
+			}
+			fmt.Println(color.YellowString("[pending] %s (ID: %s, %s): %s", v.Name, v.ID, v.Region, err))
+			continue
+		}
+
+		server, err := client.GetServer(ctx)
+		if err != nil {
+			fmt.Println(color.YellowString("[pending] %s (ID: %s, %s): %s", v.Name, v.ID, v.Region, outlineError(v.Region, err)))
+			continue
+		}
+		if server.ServerID != v.ServerID {
+			state.Remove(v.Region, v.ID)
+			notice("Drop Rotated Key (%s, ID: %s), the outline server of %s was recreated\n", v.Name, v.ID, v.Region)
+			continue
+		}
+
+		err = client.DeleteAccessKey(ctx, v.ID)
+		if err != nil && !errors.Is(err, internal.ErrOutlineNotFound) {
+			fmt.Println(color.YellowString("[pending] %s (ID: %s, %s): %s", v.Name, v.ID, v.Region, outlineError(v.Region, err)))
+			continue
+		}
+
+		state.Remove(v.Region, v.ID)
+		congratulation(fmt.Sprintf("Delete Rotated Key Success! (%s, ID: %s, %s)\n", v.Name, v.ID, v.Region))
+	}
+}
+
+func rotateAccessURL() error {
+	grace, err := internal.ParseDuration(viper.GetString("rotate-grace"))
+	if err != nil {
+		return err
+	}
+
+	statePath := filepath.Join(_credential.homePath, "rotation.json")
+	state, err := internal.LoadRotationState(statePath)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	deleteDueRotations(ctx, state)
+	if err = state.Save(statePath); err != nil {
+		return err
+	}
+
+	if viper.GetBool("rotate-cleanup") {
+		return nil
+	}
+
+	region, err := askWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	client, err := newOutlineClient(region)
+	if err != nil {
+		return err
+	}
+
+	accessKeys, err := client.GetAccessKeys(ctx)
+	if err != nil {
+		return outlineError(region, err)
+	}
+
+	// a rotated key is only waiting for its deletion
+	active := &internal.AccessKeys{}
+	for _, v := range accessKeys.Keys {
+		if !internal.IsRotated(&v) {
+			active.Keys = append(active.Keys, v)
+		}
+	}
+
+	if len(active.Keys) == 0 {
+		fmt.Println("The access key does not exist")
+		return nil
+	}
+
+	active, err = selectAccessKeys(active,
+		viper.GetString("rotate-id"),
+		viper.GetString("rotate-name"),
+		viper.GetBool("rotate-all"),
+		"Please select the access key you want to rotate:")
+	if err != nil {
+		return err
+	}
+
+	registry, err := loadKeyRegistry(ctx, region, client)
+	if err != nil {
+		return err
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+	t.AppendHeader(table.Row{"Name", "Old ID", "New ID", "AccessURL", "Delete After", "Region"})
+
+	deleteAfter := time.Now().Add(grace)
+	for i, v := range active.Keys {
+		replacement, err := internal.RotateAccessKey(ctx, client, &active.Keys[i])
+		if replacement != nil {
+			state.Add(internal.PendingRotation{
+				Region:      region,
+				ServerID:    registry.ServerID,
+				ID:          v.ID,
+				Name:        v.Name,
+				ReplacedBy:  replacement.ID,
+				DeleteAfter: deleteAfter,
+			})
+			// the replacement keeps the local metadata such as the expiry,
+			// but gets a dynamic key of its own
+			*registry.Get(replacement.ID) = *registry.Get(v.ID)
+			registry.Get(replacement.ID).DynamicKeyToken = ""
+			t.AppendRow(table.Row{v.Name, v.ID, replacement.ID, replacement.AccessURL, deleteAfter.Format(time.RFC3339), region})
+		}
+		if err != nil {
+			t.Render()
+			if saveErr := state.Save(statePath); saveErr != nil {
+				return saveErr
+			}
+			if saveErr := registry.Save(internal.KeyRegistryPath(region)); saveErr != nil {
+				return saveErr
+			}
+			return outlineError(region, err)
+		}
+	}
+
+	t.Render()
+
+	if err = state.Save(statePath); err != nil {
+		return err
+	}
+	if err = registry.Save(internal.KeyRegistryPath(region)); err != nil {
+		return err
+	}
+	congratulation(fmt.Sprintf("Rotate Success! The old keys are deleted by `outline-vpn rotate accesskey` after %s\n", deleteAfter.Format(time.RFC3339)))
+
+	return nil
+}
+
+var (
+	rotateCommand = &cobra.Command{
+		Use:       "rotate",
+		Short:     "Rotating the secrets of the outline resources",
+		Long:      "Rotating the secrets of the outline resources. The old access key keeps working for the grace period and is deleted on a later run.",
+		ValidArgs: []string{"accesskey"},
+		Args:      cobra.MatchAll(internal.WrapArgsError(cobra.MinimumNArgs(1)), cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		Run: func(_ *cobra.Command, args []string) {
+			var (
+				err error
+			)
+
+			switch args[0] {
+			case "accesskey":
+				if err = rotateAccessURL(); err != nil {
+					panicRed(err)
+				}
+			}
+		},
+	}
+)
+
+func init() {
+	rotateCommand.Flags().StringP("id", "", "", "[optional] id of the access key to rotate")
+	rotateCommand.Flags().StringP("name", "", "", "[optional] name of the access keys to rotate")
+	rotateCommand.Flags().BoolP("all", "", false, "[optional] rotate every access key of the outline server")
+	rotateCommand.Flags().StringP("grace", "", "72h", "[optional] how long the old access keys keep working, e.g. 72h or 3d")
+	rotateCommand.Flags().BoolP("cleanup", "", false, "[optional] only delete the old access keys whose grace period has passed")
+
+	viper.BindPFlag("rotate-id", rotateCommand.Flags().Lookup("id"))
+	viper.BindPFlag("rotate-name", rotateCommand.Flags().Lookup("name"))
+	viper.BindPFlag("rotate-all", rotateCommand.Flags().Lookup("all"))
+	viper.BindPFlag("rotate-grace", rotateCommand.Flags().Lookup("grace"))
+	viper.BindPFlag("rotate-cleanup", rotateCommand.Flags().Lookup("cleanup"))
+	rootCmd.AddCommand(rotateCommand)
+}
@@ -0,0 +1,252 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/ghdwlsgur/outline-vpn/internal"
+	"github.com/jedib0t/go-pretty/v6/table"
+	"github.com/spf13/cobra"
+)
+
+func newServerClient(ctx context.Context) (string, *internal.OutlineClient, error) {
+	region, err := askWorkspace(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+
+	client, err := newOutlineClient(region)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return region, client, nil
+}
+
+func showServer() error {
+	ctx := context.Background()
+	region, client, err := newServerClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	server, err := client.GetServer(ctx)
+	if err != nil {
+		return outlineError(region, err)
+	}
+
+	defaultLimit := "-"
+	if server.AccessKeyDataLimit != nil {
+		defaultLimit = internal.FormatDataSize(server.AccessKeyDataLimit.Bytes)
+	}
+
+	t := table.NewWriter()
+	t.SetOutputMirror(os.Stdout)
+
+	t.AppendHeader(table.Row{"Name", "Server ID", "Version", "Hostname", "Port", "Default Limit", "Metrics", "Created", "Region"})
+	t.AppendRow(table.Row{
+		server.Name,
+		server.ServerID,
+		server.Version,
+		server.HostnameForAccessKeys,
+		server.PortForNewAccessKeys,
+		defaultLimit,
+		server.MetricsEnabled,
+		server.GetCreatedTime(),
+		region,
+	})
+
+	t.Render()
+
+	return nil
+}
+
+func renameServer(name string) error {
+	ctx := context.Background()
+	region, client, err := newServerClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = client.RenameServer(ctx, name); err != nil {
+		return outlineError(region, err)
+	}
+	congratulation("Rename Success!\n")
+
+	return nil
+}
+
+func setServerHostname(hostname string) error {
+	ctx := context.Background()
+	region, client, err := newServerClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = client.SetHostnameForAccessKeys(ctx, hostname); err != nil {
+		return outlineError(region, err)
+	}
+	congratulation("Hostname Update Success!\n")
+
+	return nil
+}
+
+func setServerPort(value string) error {
+	port, err := strconv.Atoi(value)
+	if err != nil || port < 1 || port > 65535 {
+		return fmt.Errorf("invalid port %q", value)
+	}
+
+	ctx := context.Background()
+	region, client, err := newServerClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = client.SetPortForNewAccessKeys(ctx, port); err != nil {
+		return outlineError(region, err)
+	}
+	congratulation("Port Update Success!\n")
+
+	return nil
+}
+
+func setServerDefaultLimit(value string) error {
+	var (
+		limit int
+		err   error
+	)
+
+	if value != "none" {
+		limit, err = internal.ParseDataSize(value)
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx := context.Background()
+	region, client, err := newServerClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if value == "none" {
+		err = client.DeleteDefaultDataLimit(ctx)
+	} else {
+		err = client.AddDefaultDataLimit(ctx, limit)
+	}
+	if err != nil {
+		return outlineError(region, err)
+	}
+	congratulation("Default Data Limit Update Success!\n")
+
+	return nil
+}
+
+func setServerMetrics(value string) error {
+	ctx := context.Background()
+	region, client, err := newServerClient(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err = client.SetMetricsEnabled(ctx, value == "on"); err != nil {
+		return outlineError(region, err)
+	}
+	congratulation(fmt.Sprintf("Metrics %s Success!\n", value))
+
+	return nil
+}
+
+var (
+	serverCommand = &cobra.Command{
+		Use:   "server",
+		Short: "Managing the settings of the outline server",
+		Long:  "Managing the settings of the outline server",
+	}
+
+	serverShowCommand = &cobra.Command{
+		Use:   "show",
+		Short: "Show the settings of the outline server",
+		Long:  "Show the settings of the outline server",
+		Args:  cobra.NoArgs,
+		Run: func(_ *cobra.Command, _ []string) {
+			if err := showServer(); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+
+	serverRenameCommand = &cobra.Command{
+		Use:   "rename [name]",
+		Short: "Rename the outline server",
+		Long:  "Rename the outline server",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			if err := renameServer(args[0]); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+
+	serverSetHostnameCommand = &cobra.Command{
+		Use:   "set-hostname [hostname]",
+		Short: "Set the hostname or IP address used in new access keys",
+		Long:  "Set the hostname or IP address used in new access keys",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			if err := setServerHostname(args[0]); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+
+	serverSetPortCommand = &cobra.Command{
+		Use:   "set-port [port]",
+		Short: "Set the port used by new access keys",
+		Long:  "Set the port used by new access keys",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			if err := setServerPort(args[0]); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+
+	serverSetDefaultLimitCommand = &cobra.Command{
+		Use:   "set-default-limit [size|none]",
+		Short: "Set the data limit applied to every access key (e.g. 50GB), none removes it",
+		Long:  "Set the data limit applied to every access key (e.g. 50GB), none removes it",
+		Args:  cobra.ExactArgs(1),
+		Run: func(_ *cobra.Command, args []string) {
+			if err := setServerDefaultLimit(args[0]); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+
+	serverMetricsCommand = &cobra.Command{
+		Use:       "metrics",
+		Short:     "Enable or disable sharing metrics of the outline server",
+		Long:      "Enable or disable sharing metrics of the outline server",
+		ValidArgs: []string{"on", "off"},
+		Args:      cobra.MatchAll(internal.WrapArgsError(cobra.MinimumNArgs(1)), cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		Run: func(_ *cobra.Command, args []string) {
+			if err := setServerMetrics(args[0]); err != nil {
+				panicRed(err)
+			}
+		},
+	}
+)
+
+func init() {
+	serverCommand.AddCommand(serverShowCommand)
+	serverCommand.AddCommand(serverRenameCommand)
+	serverCommand.AddCommand(serverSetHostnameCommand)
+	serverCommand.AddCommand(serverSetPortCommand)
+	serverCommand.AddCommand(serverSetDefaultLimitCommand)
+	serverCommand.AddCommand(serverMetricsCommand)
+	rootCmd.AddCommand(serverCommand)
+}
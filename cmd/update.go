@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/ghdwlsgur/outline-vpn/internal"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	updateRename     = "Rename"
+	updateLimit      = "Set data limit"
+	updateClearLimit = "Remove data limit"
+)
+
+func findAccessKey(accessKeys *internal.AccessKeys, id string) (*internal.AccessKey, error) {
+	for i, v := range accessKeys.Keys {
+		if v.ID == id {
+			return &accessKeys.Keys[i], nil
+		}
+	}
+	return nil, fmt.Errorf("the access key %s does not exist", id)
+}
+
+// askUpdate asks what to change on the access key when no update flag is given.
+func askUpdate(accessKey *internal.AccessKey) (name, limit string, clearLimit bool, err error) {
+	answer, err := internal.AskPromptOptionList("What do you want to update:",
+		[]string{updateRename, updateLimit, updateClearLimit}, 3)
+	if err != nil {
+		return "", "", false, err
+	}
+
+	switch answer {
+	case updateRename:
+		name, err = internal.AskInput("New name of the access key:", accessKey.Name)
+	case updateLimit:
+		limit, err = internal.AskInput("Data limit (e.g. 50GB):", "")
+	case updateClearLimit:
+		clearLimit = true
+	}
+	return name, limit, clearLimit, err
+}
+
+func updateAccessURL() error {
+	var (
+		accessKey  *internal.AccessKey
+		name       = viper.GetString("update-rename")
+		limit      = viper.GetString("update-limit")
+		clearLimit = viper.GetBool("update-clear-limit")
+	)
+
+	if limit != "" && clearLimit {
+		return fmt.Errorf("--limit and --clear-limit can't be used together")
+	}
+
+	ctx := context.Background()
+	region, err := askWorkspace(ctx)
+	if err != nil {
+		return err
+	}
+
+	client, err := newOutlineClient(region)
+	if err != nil {
+		return err
+	}
+
+	accessKeys, err := client.GetAccessKeys(ctx)
+	if err != nil {
+		return outlineError(region, err)
+	}
+
+	if len(accessKeys.Keys) == 0 {
+		fmt.Println("The access key does not exist")
+		return nil
+	}
+
+	if id := viper.GetString("update-id"); id != "" {
+		accessKey, err = findAccessKey(accessKeys, id)
+	} else {
+		accessKey, err = askAccessKey(accessKeys, "Please select the access key you want to update:")
+	}
+	if err != nil {
+		return err
+	}
+
+	registry, err := loadKeyRegistry(ctx, region, client)
+	if err != nil {
+		return err
+	}
+	if err = reconcileKeyRegistry(region, registry, accessKeys); err != nil {
+		return err
+	}
+
+	if setKeyMetadata("update", registry.Get(accessKey.ID)) {
+		if err = registry.Save(internal.KeyRegistryPath(region)); err != nil {
+			return err
+		}
+		congratulation("Metadata Update Success!\n")
+	} else if name == "" && limit == "" && !clearLimit {
+		if !internal.IsTerminal() {
+			return fmt.Errorf("--rename, --limit, --clear-limit, --owner, --email, --tag or --notes is required when stdin is not a terminal")
+		}
+		name, limit, clearLimit, err = askUpdate(accessKey)
+		if err != nil {
+			return err
+		}
+	}
+
+	if name != "" {
+		if err = client.RenameAccessKey(ctx, accessKey.ID, name); err != nil {
+			return outlineError(region, err)
+		}
+		congratulation("Rename Success!\n")
+	}
+
+	if limit != "" {
+		bytes, err := internal.ParseDataSize(limit)
+		if err != nil {
+			return err
+		}
+
+		if err = client.AddDataLimitAccessKey(ctx, accessKey.ID, bytes); err != nil {
+			return outlineError(region, err)
+		}
+		congratulation(fmt.Sprintf("Data Limit %s Success!\n", internal.FormatDataSize(bytes)))
+	}
+
+	if clearLimit {
+		if err = client.DeleteDataLimitAccessKey(ctx, accessKey.ID); err != nil {
+			return outlineError(region, err)
+		}
+		congratulation("Remove Data Limit Success!\n")
+	}
+
+	return nil
+}
+
+var (
+	updateCommand = &cobra.Command{
+		Use:       "update",
+		Short:     "Updating the outline resources",
+		Long:      "Updating the outline resources",
+		ValidArgs: []string{"accesskey"},
+		Args:      cobra.MatchAll(internal.WrapArgsError(cobra.MinimumNArgs(1)), cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		Run: func(_ *cobra.Command, args []string) {
+			var (
+				err error
+			)
+
+			switch args[0] {
+			case "accesskey":
+				if err = updateAccessURL(); err != nil {
+					panicRed(err)
+				}
+			}
+		},
+	}
+)
+
+func init() {
+	updateCommand.Flags().StringP("id", "", "", "[optional] id of the access key to update (default is to choose it)")
+	updateCommand.Flags().StringP("rename", "", "", "[optional] new name of the access key")
+	updateCommand.Flags().StringP("limit", "", "", "[optional] data limit of the access key in human units (e.g. 50GB)")
+	updateCommand.Flags().BoolP("clear-limit", "", false, "[optional] remove the data limit of the access key")
+	updateCommand.Flags().StringP("owner", "", "", "[optional] owner of the access key, kept locally")
+	updateCommand.Flags().StringP("email", "", "", "[optional] email of the owner, kept locally")
+	updateCommand.Flags().StringSliceP("tag", "", nil, "[optional] team tags of the access key replacing the current ones, kept locally")
+	updateCommand.Flags().StringP("notes", "", "", "[optional] free-form notes about the access key, kept locally")
+
+	viper.BindPFlag("update-id", updateCommand.Flags().Lookup("id"))
+	viper.BindPFlag("update-rename", updateCommand.Flags().Lookup("rename"))
+	viper.BindPFlag("update-limit", updateCommand.Flags().Lookup("limit"))
+	viper.BindPFlag("update-clear-limit", updateCommand.Flags().Lookup("clear-limit"))
+	viper.BindPFlag("update-owner", updateCommand.Flags().Lookup("owner"))
+	viper.BindPFlag("update-email", updateCommand.Flags().Lookup("email"))
+	viper.BindPFlag("update-tag", updateCommand.Flags().Lookup("tag"))
+	viper.BindPFlag("update-notes", updateCommand.Flags().Lookup("notes"))
+	rootCmd.AddCommand(updateCommand)
+}
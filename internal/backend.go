@@ -0,0 +1,238 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+	BackendHTTP  = "http"
+
+	defaultBackendKeyPrefix = "outline-vpn"
+	backendRegionHolder     = "{region}"
+	backendStateName        = "terraform.tfstate"
+
+	// moduleOutlineStateVersion is the first version of the embedded module that keeps outline.json in the state.
+	moduleOutlineStateVersion = "0.2.0"
+)
+
+// Backend is where terraform keeps the state of the workspaces, every
+// workspace (region) has its own state in it. The local backend keeps the
+// state in terraform.tfstate.d of the workspace as before.
+//
+// The credentials of the http backend are not saved, terraform reads them
+// from TF_HTTP_USERNAME and TF_HTTP_PASSWORD.
+type Backend struct {
+	Type string `json:"type"`
+
+	// s3, the state of a workspace is <keyPrefix>/<region>/terraform.tfstate
+	Bucket    string `json:"bucket,omitempty"`
+	Region    string `json:"region,omitempty"`
+	LockTable string `json:"lockTable,omitempty"`
+	KeyPrefix string `json:"keyPrefix,omitempty"`
+
+	// http, {region} in the addresses is replaced by the workspace,
+	// otherwise the workspace is appended to the state address
+	Address       string `json:"address,omitempty"`
+	LockAddress   string `json:"lockAddress,omitempty"`
+	UnlockAddress string `json:"unlockAddress,omitempty"`
+}
+
+// LoadBackend reads the backend configuration, without one the state is local.
+func LoadBackend(path string) (*Backend, error) {
+	backend := &Backend{Type: BackendLocal}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return backend, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(b, backend); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return backend, backend.Validate()
+}
+
+func (b *Backend) Save(path string) error {
+	if err := b.Validate(); err != nil {
+		return err
+	}
+
+	buf, err := json.MarshalIndent(b, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf, 0600)
+}
+
+func (b *Backend) Validate() error {
+	switch b.Type {
+	case BackendLocal, "":
+	case BackendS3:
+		if b.Bucket == "" || b.Region == "" {
+			return fmt.Errorf("the s3 backend needs a bucket and its region")
+		}
+	case BackendHTTP:
+		if b.Address == "" {
+			return fmt.Errorf("the http backend needs an address")
+		}
+	default:
+		return fmt.Errorf("invalid backend %q (%s, %s, %s)", b.Type, BackendLocal, BackendS3, BackendHTTP)
+	}
+	return nil
+}
+
+// IsLocal reports whether the state stays in the workspace directories.
+func (b *Backend) IsLocal() bool {
+	return b == nil || b.Type == "" || b.Type == BackendLocal
+}
+
+// Key is the object of the state of a workspace in the s3 bucket.
+func (b *Backend) Key(region string) string {
+	return fmt.Sprintf("%s/%s/%s", b.keyPrefix(), region, backendStateName)
+}
+
+func (b *Backend) keyPrefix() string {
+	prefix := strings.Trim(b.KeyPrefix, "/")
+	if prefix == "" {
+		prefix = defaultBackendKeyPrefix
+	}
+	return prefix
+}
+
+// RegionOfKey is the workspace of a state object in the s3 bucket.
+func (b *Backend) RegionOfKey(key string) (string, bool) {
+	region, ok := strings.CutSuffix(key, "/"+backendStateName)
+	if !ok {
+		return "", false
+	}
+
+	region, ok = strings.CutPrefix(region, b.keyPrefix()+"/")
+	if !ok || region == "" || strings.Contains(region, "/") {
+		return "", false
+	}
+	return region, true
+}
+
+// Addresses are the state, lock and unlock addresses of a workspace on the http backend.
+func (b *Backend) Addresses(region string) (string, string, string) {
+	address := b.Address
+	if strings.Contains(address, backendRegionHolder) {
+		address = strings.ReplaceAll(address, backendRegionHolder, region)
+	} else {
+		address = strings.TrimSuffix(address, "/") + "/" + region
+	}
+
+	return address,
+		strings.ReplaceAll(b.LockAddress, backendRegionHolder, region),
+		strings.ReplaceAll(b.UnlockAddress, backendRegionHolder, region)
+}
+
+// BackendRegions lists the workspaces that have a state in the s3 bucket,
+// an http backend can't be listed.
+func BackendRegions(ctx context.Context, cfg aws.Config, backend *Backend) ([]string, error) {
+	if backend.Type != BackendS3 {
+		return nil, fmt.Errorf("the workspaces of the %s backend can't be listed, name the regions", backend.Type)
+	}
+
+	cfg.Region = backend.Region
+	paginator := s3.NewListObjectsV2Paginator(s3.NewFromConfig(cfg), &s3.ListObjectsV2Input{
+		Bucket: aws.String(backend.Bucket),
+		Prefix: aws.String(backend.keyPrefix() + "/"),
+	})
+
+	regions := make([]string, 0)
+	for paginator.HasMorePages() {
+		page, err := paginator.NextPage(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, object := range page.Contents {
+			if region, ok := backend.RegionOfKey(aws.ToString(object.Key)); ok {
+				regions = append(regions, region)
+			}
+		}
+	}
+	return regions, nil
+}
+
+// RemoteWorkspace is what the state of a workspace in the backend holds to
+// write the workspace again on another machine: the variables of main.tf and
+// the files the outline-vpn module wrote when the server was provisioned.
+type RemoteWorkspace struct {
+	EC2Ami           string
+	InstanceType     string
+	AvailabilityZone string
+
+	// outline.json, the management api of the server
+	Outline string
+	// access_key.json, the first access key the module reads
+	AccessKey string
+}
+
+// NewRemoteWorkspace reads the workspace from the state of terraform show.
+func NewRemoteWorkspace(state *tfjson.State) (*RemoteWorkspace, error) {
+	if state == nil || state.Values == nil || state.Values.RootModule == nil {
+		return nil, fmt.Errorf("there is no state in the backend")
+	}
+
+	resources := make(map[string]map[string]interface{})
+	for _, module := range state.Values.RootModule.ChildModules {
+		for _, resource := range module.Resources {
+			resources[resource.Address] = resource.AttributeValues
+		}
+	}
+
+	prefix := "module." + moduleName + "."
+	instance, ok := resources[prefix+"aws_instance.outline"]
+	if !ok {
+		return nil, fmt.Errorf("there is no EC2 instance in the state")
+	}
+	outline, ok := resources[prefix+"data.local_file.outline"]
+	if !ok {
+		return nil, fmt.Errorf("there is no outline.json in the state, apply the workspace again with the embedded module %s or later", moduleOutlineStateVersion)
+	}
+	accessKey := resources[prefix+"data.local_file.access_key"]
+
+	str := func(values map[string]interface{}, name string) string {
+		s, _ := values[name].(string)
+		return s
+	}
+
+	workspace := &RemoteWorkspace{
+		EC2Ami:           str(instance, "ami"),
+		InstanceType:     str(instance, "instance_type"),
+		AvailabilityZone: str(instance, "availability_zone"),
+		Outline:          str(outline, "content"),
+		AccessKey:        str(accessKey, "content"),
+	}
+	if workspace.Outline == "" {
+		return nil, fmt.Errorf("outline.json in the state is empty")
+	}
+	return workspace, nil
+}
+
+// WriteFiles writes outline.json and access_key.json into the workspace.
+func (w *RemoteWorkspace) WriteFiles(workSpacePath string) error {
+	if err := os.WriteFile(filepath.Join(workSpacePath, "outline.json"), []byte(w.Outline), 0644); err != nil {
+		return err
+	}
+	if w.AccessKey == "" {
+		return nil
+	}
+	return os.WriteFile(filepath.Join(workSpacePath, "access_key.json"), []byte(w.AccessKey), 0600)
+}
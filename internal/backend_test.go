@@ -0,0 +1,159 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackend(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "backend.json")
+
+	backend, err := LoadBackend(path)
+	assert.NoError(err)
+	assert.True(backend.IsLocal())
+
+	s3 := &Backend{Type: BackendS3, Bucket: "state", Region: "us-east-1", LockTable: "lock"}
+	assert.NoError(s3.Save(path))
+	backend, err = LoadBackend(path)
+	assert.NoError(err)
+	assert.Equal(s3, backend)
+	assert.Equal("outline-vpn/ap-northeast-2/terraform.tfstate", backend.Key("ap-northeast-2"))
+
+	tests := map[string]struct {
+		input *Backend
+		isErr bool
+	}{
+		"local":     {input: &Backend{Type: BackendLocal}},
+		"s3":        {input: &Backend{Type: BackendS3, Bucket: "state", Region: "us-east-1"}},
+		"s3-bucket": {input: &Backend{Type: BackendS3, Region: "us-east-1"}, isErr: true},
+		"http":      {input: &Backend{Type: BackendHTTP, Address: "https://state.example.com"}},
+		"http-addr": {input: &Backend{Type: BackendHTTP}, isErr: true},
+		"type":      {input: &Backend{Type: "gcs"}, isErr: true},
+	}
+
+	for name, test := range tests {
+		err := test.input.Validate()
+		assert.Equal(test.isErr, err != nil, name)
+	}
+}
+
+func TestBackendAddresses(t *testing.T) {
+	assert := assert.New(t)
+
+	backend := &Backend{Type: BackendHTTP, Address: "https://gitlab.example.com/api/v4/projects/1/terraform/state/"}
+	address, lock, unlock := backend.Addresses("us-east-1")
+	assert.Equal("https://gitlab.example.com/api/v4/projects/1/terraform/state/us-east-1", address)
+	assert.Equal("", lock)
+	assert.Equal("", unlock)
+
+	backend = &Backend{
+		Type:          BackendHTTP,
+		Address:       "https://state.example.com/{region}/state",
+		LockAddress:   "https://state.example.com/{region}/lock",
+		UnlockAddress: "https://state.example.com/{region}/lock",
+	}
+	address, lock, unlock = backend.Addresses("us-east-1")
+	assert.Equal("https://state.example.com/us-east-1/state", address)
+	assert.Equal("https://state.example.com/us-east-1/lock", lock)
+	assert.Equal("https://state.example.com/us-east-1/lock", unlock)
+}
+
+func TestCreateBackendDotTf(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	fileName := filepath.Join(dir, "backend.tf")
+
+	assert.NoError(CreateBackendDotTf(dir, "us-east-1", &Backend{Type: BackendS3, Bucket: "state", Region: "eu-west-1", LockTable: "lock"}))
+	b, err := os.ReadFile(fileName)
+	assert.NoError(err)
+	assert.Equal(`terraform {
+  backend "s3" {
+    bucket         = "state"
+    key            = "outline-vpn/us-east-1/terraform.tfstate"
+    region         = "eu-west-1"
+    encrypt        = true
+    dynamodb_table = "lock"
+  }
+}
+`, string(b))
+
+	// back to the local state
+	assert.NoError(CreateBackendDotTf(dir, "us-east-1", &Backend{Type: BackendLocal}))
+	_, err = os.Stat(fileName)
+	assert.True(os.IsNotExist(err))
+	assert.NoError(CreateBackendDotTf(dir, "us-east-1", nil))
+}
+
+func TestBackendRegionOfKey(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]struct {
+		backend *Backend
+		key     string
+		region  string
+		ok      bool
+	}{
+		"default":     {backend: &Backend{Type: BackendS3}, key: "outline-vpn/us-east-1/terraform.tfstate", region: "us-east-1", ok: true},
+		"prefix":      {backend: &Backend{Type: BackendS3, KeyPrefix: "/team/vpn/"}, key: "team/vpn/eu-west-1/terraform.tfstate", region: "eu-west-1", ok: true},
+		"other":       {backend: &Backend{Type: BackendS3}, key: "other/us-east-1/terraform.tfstate"},
+		"nested":      {backend: &Backend{Type: BackendS3}, key: "outline-vpn/a/us-east-1/terraform.tfstate"},
+		"not a state": {backend: &Backend{Type: BackendS3}, key: "outline-vpn/us-east-1/terraform.tfstate.backup"},
+	}
+
+	for name, test := range tests {
+		region, ok := test.backend.RegionOfKey(test.key)
+		assert.Equal(test.ok, ok, name)
+		assert.Equal(test.region, region, name)
+	}
+}
+
+func TestNewRemoteWorkspace(t *testing.T) {
+	assert := assert.New(t)
+
+	outline := `{"ManagementUdpPort":8081,"VpnTcpUdpPort":443,"ApiUrl":"https://203.0.113.10:8081/secret","CertSha256":"ABCD"}`
+	state := &tfjson.State{Values: &tfjson.StateValues{RootModule: &tfjson.StateModule{
+		Resources: []*tfjson.StateResource{
+			{Address: "aws_key_pair.govpn_key", AttributeValues: map[string]interface{}{"key_name": "govpn_us-east-1"}},
+		},
+		ChildModules: []*tfjson.StateModule{{
+			Address: "module.outline-vpn",
+			Resources: []*tfjson.StateResource{
+				{Address: "module.outline-vpn.aws_instance.outline", AttributeValues: map[string]interface{}{
+					"ami": "ami-0a1b2c3d4e5f", "instance_type": "t2.micro", "availability_zone": "us-east-1a",
+				}},
+				{Address: "module.outline-vpn.data.local_file.outline", AttributeValues: map[string]interface{}{"content": outline}},
+				{Address: "module.outline-vpn.data.local_file.access_key", AttributeValues: map[string]interface{}{"content": `{"id":"0"}`}},
+			},
+		}},
+	}}}
+
+	workspace, err := NewRemoteWorkspace(state)
+	assert.NoError(err)
+	assert.Equal(&RemoteWorkspace{
+		EC2Ami:           "ami-0a1b2c3d4e5f",
+		InstanceType:     "t2.micro",
+		AvailabilityZone: "us-east-1a",
+		Outline:          outline,
+		AccessKey:        `{"id":"0"}`,
+	}, workspace)
+
+	dir := t.TempDir()
+	assert.NoError(workspace.WriteFiles(dir))
+	b, err := os.ReadFile(filepath.Join(dir, "outline.json"))
+	assert.NoError(err)
+	assert.Equal(outline, string(b))
+
+	// no state, or a state without the outline server
+	_, err = NewRemoteWorkspace(&tfjson.State{})
+	assert.Error(err)
+	state.Values.RootModule.ChildModules[0].Resources = state.Values.RootModule.ChildModules[0].Resources[:1]
+	_, err = NewRemoteWorkspace(state)
+	assert.Error(err)
+}
@@ -5,9 +5,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path"
 	"regexp"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/AlecAivazis/survey/v2"
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -15,6 +17,7 @@ import (
 	ec2_types "github.com/aws/aws-sdk-go-v2/service/ec2/types"
 	"github.com/fatih/color"
 	"github.com/hairyhenderson/go-which"
+	"golang.org/x/term"
 )
 
 type (
@@ -23,6 +26,8 @@ type (
 	}
 )
 
+var unsafeFileName = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
 type OutlineInfo struct {
 	ManagementUdpPort int    `json:"ManagementUdpPort"`
 	VpnTcpUdpPort     int    `json:"VpnTcpUdpPort"`
@@ -34,14 +39,46 @@ type DataLimit struct {
 	Bytes int `json:"bytes"`
 }
 
+type OutlineServer struct {
+	Name                  string     `json:"name"`
+	ServerID              string     `json:"serverId"`
+	MetricsEnabled        bool       `json:"metricsEnabled"`
+	CreatedTimestampMs    int64      `json:"createdTimestampMs"`
+	Version               string     `json:"version"`
+	PortForNewAccessKeys  int        `json:"portForNewAccessKeys"`
+	HostnameForAccessKeys string     `json:"hostnameForAccessKeys"`
+	AccessKeyDataLimit    *DataLimit `json:"accessKeyDataLimit,omitempty"`
+}
+
+func (s *OutlineServer) GetCreatedTime() string {
+	return time.UnixMilli(s.CreatedTimestampMs).String()
+}
+
+// AccessKey has no DataLimit without a limit of its own, a limit of 0 bytes blocks the key.
 type AccessKey struct {
-	ID        string `json:"id"`
-	Name      string `json:"name"`
-	Password  string `json:"password"`
-	Port      int    `json:"port"`
-	Method    string `json:"method"`
-	DataLimit DataLimit
-	AccessURL string `json:"accessUrl"`
+	ID        string     `json:"id"`
+	Name      string     `json:"name"`
+	Password  string     `json:"password"`
+	Port      int        `json:"port"`
+	Method    string     `json:"method"`
+	DataLimit *DataLimit `json:"dataLimit,omitempty"`
+	AccessURL string     `json:"accessUrl"`
+}
+
+func (k *AccessKey) GetDataLimit() string {
+	if k.DataLimit == nil {
+		return "-"
+	}
+	return FormatDataSize(k.DataLimit.Bytes)
+}
+
+// FileName is the name of the access key made safe for a file, an unnamed key is called accesskey-<id>.
+func (k *AccessKey) FileName() string {
+	name := unsafeFileName.ReplaceAllString(k.Name, "_")
+	if name == "" || name == "_" {
+		return fmt.Sprintf("accesskey-%s", k.ID)
+	}
+	return name
 }
 
 type AccessKeys struct {
@@ -57,6 +94,48 @@ func (l *AccessKeys) GetAccessUrlList() []string {
 	return result
 }
 
+// Filter returns the access keys matching the given id and name, an empty value matches every key.
+func (l *AccessKeys) Filter(id, name string) *AccessKeys {
+	result := &AccessKeys{Keys: make([]AccessKey, 0, len(l.Keys))}
+	for _, v := range l.Keys {
+		if (id == "" || v.ID == id) && (name == "" || v.Name == name) {
+			result.Keys = append(result.Keys, v)
+		}
+	}
+	return result
+}
+
+// FilterNamePattern returns the access keys whose name matches the glob pattern (e.g. "sales-*").
+func (l *AccessKeys) FilterNamePattern(pattern string) (*AccessKeys, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, fmt.Errorf("invalid name pattern %q", pattern)
+	}
+
+	result := &AccessKeys{Keys: make([]AccessKey, 0, len(l.Keys))}
+	for _, v := range l.Keys {
+		if ok, _ := path.Match(pattern, v.Name); ok {
+			result.Keys = append(result.Keys, v)
+		}
+	}
+	return result, nil
+}
+
+// Except returns the access keys whose id or name is not in except.
+func (l *AccessKeys) Except(except []string) *AccessKeys {
+	skip := make(map[string]bool, len(except))
+	for _, v := range except {
+		skip[v] = true
+	}
+
+	result := &AccessKeys{Keys: make([]AccessKey, 0, len(l.Keys))}
+	for _, v := range l.Keys {
+		if !skip[v.ID] && (v.Name == "" || !skip[v.Name]) {
+			result.Keys = append(result.Keys, v)
+		}
+	}
+	return result
+}
+
 func ReturnTerraformPath(region string) string {
 	path := which.Which("outline-vpn")
 	path = strings.Replace(path, "bin", "lib", -1)
@@ -203,6 +282,11 @@ func PrintProvisioning(cmd, title, content string) {
 		color.HiGreenString(content))
 }
 
+// IsTerminal reports whether stdin is attached to a terminal, i.e. whether survey prompts can be answered.
+func IsTerminal() bool {
+	return term.IsTerminal(int(os.Stdin.Fd()))
+}
+
 func AskPrompt(Message, AnswerOne, AnswerTwo string) (string, error) {
 
 	prompt := &survey.Select{
@@ -236,6 +320,36 @@ func AskPromptOptionList(Message string, Options []string, size int) (string, er
 	return answer, nil
 }
 
+func AskPromptMultiSelect(Message string, Options []string, size int) ([]string, error) {
+	prompt := &survey.MultiSelect{
+		Message: Message,
+		Options: Options,
+	}
+
+	answer := make([]string, 0)
+	if err := survey.AskOne(prompt, &answer, survey.WithIcons(func(icons *survey.IconSet) {
+		icons.SelectFocus.Format = "green+hb"
+	}), survey.WithPageSize(size)); err != nil {
+		return nil, err
+	}
+
+	return answer, nil
+}
+
+func AskInput(Message, Default string) (string, error) {
+	prompt := &survey.Input{
+		Message: Message,
+		Default: Default,
+	}
+
+	answer := ""
+	if err := survey.AskOne(prompt, &answer, survey.WithValidator(survey.Required)); err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(answer), nil
+}
+
 func AskTerraformExecution(Message string) (string, error) {
 	prompt := &survey.Select{
 		Message: Message,
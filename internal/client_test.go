@@ -3,6 +3,8 @@ package internal
 import (
 	"fmt"
 	"testing"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestGetAccessKeys(t *testing.T) {
@@ -53,3 +55,86 @@ func TestGetApiURL(t *testing.T) {
 
 	fmt.Println(result)
 }
+
+func TestAccessKeysFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	accessKeys := &AccessKeys{Keys: []AccessKey{
+		{ID: "1", Name: "alice"},
+		{ID: "2", Name: "bob"},
+		{ID: "3", Name: "alice"},
+	}}
+
+	tests := map[string]struct {
+		id    string
+		name  string
+		count int
+	}{
+		"all":     {count: 3},
+		"id":      {id: "2", count: 1},
+		"name":    {name: "alice", count: 2},
+		"both":    {id: "3", name: "alice", count: 1},
+		"nothing": {id: "2", name: "alice", count: 0},
+	}
+
+	for _, t := range tests {
+		assert.Equal(t.count, len(accessKeys.Filter(t.id, t.name).Keys))
+	}
+}
+
+func TestAccessKeysFilterNamePattern(t *testing.T) {
+	assert := assert.New(t)
+
+	accessKeys := &AccessKeys{Keys: []AccessKey{
+		{ID: "1", Name: "sales-alice"},
+		{ID: "2", Name: "sales-bob"},
+		{ID: "3", Name: "dev-carol"},
+		{ID: "4"},
+	}}
+
+	tests := map[string]struct {
+		pattern string
+		ids     []string
+		isErr   bool
+	}{
+		"prefix":  {pattern: "sales-*", ids: []string{"1", "2"}},
+		"suffix":  {pattern: "*-carol", ids: []string{"3"}},
+		"exact":   {pattern: "sales-bob", ids: []string{"2"}},
+		"class":   {pattern: "[ds]*-[bc]*", ids: []string{"2", "3"}},
+		"nothing": {pattern: "ops-*", ids: []string{}},
+		"invalid": {pattern: "[", isErr: true},
+	}
+
+	for name, test := range tests {
+		filtered, err := accessKeys.FilterNamePattern(test.pattern)
+		assert.Equal(test.isErr, err != nil, name)
+		if err != nil {
+			continue
+		}
+
+		ids := make([]string, 0)
+		for _, v := range filtered.Keys {
+			ids = append(ids, v.ID)
+		}
+		assert.Equal(test.ids, ids, name)
+	}
+}
+
+func TestAccessKeysExcept(t *testing.T) {
+	assert := assert.New(t)
+
+	accessKeys := &AccessKeys{Keys: []AccessKey{
+		{ID: "1", Name: "alice"},
+		{ID: "2", Name: "bob"},
+		{ID: "3", Name: "1"},
+		{ID: "4"},
+	}}
+
+	ids := make([]string, 0)
+	for _, v := range accessKeys.Except([]string{"bob", "1"}).Keys {
+		ids = append(ids, v.ID)
+	}
+	assert.Equal([]string{"4"}, ids)
+
+	assert.Len(accessKeys.Except(nil).Keys, 4)
+}
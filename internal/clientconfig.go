@@ -0,0 +1,160 @@
+package internal
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	ClientFormatSIP002  = "sip002"
+	ClientFormatClash   = "clash"
+	ClientFormatSingbox = "singbox"
+	ClientFormatSSLocal = "ss-local"
+)
+
+var ClientFormats = []string{ClientFormatSIP002, ClientFormatClash, ClientFormatSingbox, ClientFormatSSLocal}
+
+type clashProxy struct {
+	Name     string `yaml:"name"`
+	Type     string `yaml:"type"`
+	Server   string `yaml:"server"`
+	Port     int    `yaml:"port"`
+	Cipher   string `yaml:"cipher"`
+	Password string `yaml:"password"`
+	UDP      bool   `yaml:"udp"`
+}
+
+type singboxOutbound struct {
+	Type       string `json:"type"`
+	Tag        string `json:"tag"`
+	Server     string `json:"server"`
+	ServerPort int    `json:"server_port"`
+	Method     string `json:"method"`
+	Password   string `json:"password"`
+}
+
+type ssLocalConfig struct {
+	Server       string `json:"server"`
+	ServerPort   int    `json:"server_port"`
+	LocalAddress string `json:"local_address"`
+	LocalPort    int    `json:"local_port"`
+	Password     string `json:"password"`
+	Method       string `json:"method"`
+	Mode         string `json:"mode"`
+}
+
+// clientConfigTags names every access key for the client, a key without a
+// name or sharing its name with another key gets its id appended.
+func clientConfigTags(accessKeys []AccessKey) []string {
+	count := make(map[string]int)
+	for _, v := range accessKeys {
+		count[v.Name]++
+	}
+
+	tags := make([]string, len(accessKeys))
+	for i, v := range accessKeys {
+		switch {
+		case v.Name == "":
+			tags[i] = fmt.Sprintf("accesskey-%s", v.ID)
+		case count[v.Name] > 1:
+			tags[i] = fmt.Sprintf("%s-%s", v.Name, v.ID)
+		default:
+			tags[i] = v.Name
+		}
+	}
+	return tags
+}
+
+// SIP002URL is the ss:// uri of the access key described by SIP002,
+// https://shadowsocks.org/doc/sip002.html
+func SIP002URL(accessKey *AccessKey, server, tag string) string {
+	userInfo := base64.RawURLEncoding.EncodeToString([]byte(accessKey.Method + ":" + accessKey.Password))
+	u := url.URL{
+		Scheme:   "ss",
+		User:     url.User(userInfo),
+		Host:     net.JoinHostPort(server, strconv.Itoa(accessKey.Port)),
+		Fragment: tag,
+	}
+	return u.String()
+}
+
+// ClientConfig converts the access keys into the config of a third-party
+// shadowsocks client. server is the host of the outline server.
+func ClientConfig(accessKeys []AccessKey, server, format string) ([]byte, error) {
+	if len(accessKeys) == 0 {
+		return nil, fmt.Errorf("no access key to export")
+	}
+
+	tags := clientConfigTags(accessKeys)
+
+	switch format {
+	case ClientFormatSIP002:
+		var sb strings.Builder
+		for i := range accessKeys {
+			sb.WriteString(SIP002URL(&accessKeys[i], server, tags[i]))
+			sb.WriteString("\n")
+		}
+		return []byte(sb.String()), nil
+
+	case ClientFormatClash:
+		proxies := make([]clashProxy, len(accessKeys))
+		for i, v := range accessKeys {
+			proxies[i] = clashProxy{
+				Name:     tags[i],
+				Type:     "ss",
+				Server:   server,
+				Port:     v.Port,
+				Cipher:   v.Method,
+				Password: v.Password,
+				UDP:      true,
+			}
+		}
+		return yaml.Marshal(map[string][]clashProxy{"proxies": proxies})
+
+	case ClientFormatSingbox:
+		outbounds := make([]singboxOutbound, len(accessKeys))
+		for i, v := range accessKeys {
+			outbounds[i] = singboxOutbound{
+				Type:       "shadowsocks",
+				Tag:        tags[i],
+				Server:     server,
+				ServerPort: v.Port,
+				Method:     v.Method,
+				Password:   v.Password,
+			}
+		}
+		b, err := json.MarshalIndent(map[string][]singboxOutbound{"outbounds": outbounds}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+
+	case ClientFormatSSLocal:
+		// a shadowsocks-libev config holds exactly one server
+		if len(accessKeys) != 1 {
+			return nil, fmt.Errorf("%s exports a single access key, choose it with --id or --name", format)
+		}
+		b, err := json.MarshalIndent(&ssLocalConfig{
+			Server:       server,
+			ServerPort:   accessKeys[0].Port,
+			LocalAddress: "127.0.0.1",
+			LocalPort:    1080,
+			Password:     accessKeys[0].Password,
+			Method:       accessKeys[0].Method,
+			Mode:         "tcp_and_udp",
+		}, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		return append(b, '\n'), nil
+	}
+
+	return nil, fmt.Errorf("unsupported format %q (%s)", format, strings.Join(ClientFormats, ", "))
+}
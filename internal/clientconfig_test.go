@@ -0,0 +1,69 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientConfig(t *testing.T) {
+	assert := assert.New(t)
+
+	alice := AccessKey{ID: "1", Name: "alice", Password: "secret", Port: 443, Method: "chacha20-ietf-poly1305"}
+	bob := AccessKey{ID: "2", Name: "bob", Password: "hunter2", Port: 8443, Method: "aes-256-gcm"}
+	unnamed := AccessKey{ID: "3", Password: "pw", Port: 443, Method: "aes-128-gcm"}
+
+	tests := map[string]struct {
+		keys   []AccessKey
+		format string
+		output string
+		isErr  bool
+	}{
+		"sip002": {
+			keys:   []AccessKey{alice, unnamed},
+			format: ClientFormatSIP002,
+			output: "ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@1.2.3.4:443#alice\n" +
+				"ss://YWVzLTEyOC1nY206cHc@1.2.3.4:443#accesskey-3\n",
+		},
+		"clash": {
+			keys:   []AccessKey{alice, bob},
+			format: ClientFormatClash,
+			output: "proxies:\n" +
+				"    - name: alice\n      type: ss\n      server: 1.2.3.4\n      port: 443\n      cipher: chacha20-ietf-poly1305\n      password: secret\n      udp: true\n" +
+				"    - name: bob\n      type: ss\n      server: 1.2.3.4\n      port: 8443\n      cipher: aes-256-gcm\n      password: hunter2\n      udp: true\n",
+		},
+		"singbox": {
+			keys:   []AccessKey{bob},
+			format: ClientFormatSingbox,
+			output: "{\n  \"outbounds\": [\n    {\n      \"type\": \"shadowsocks\",\n      \"tag\": \"bob\",\n      \"server\": \"1.2.3.4\",\n" +
+				"      \"server_port\": 8443,\n      \"method\": \"aes-256-gcm\",\n      \"password\": \"hunter2\"\n    }\n  ]\n}\n",
+		},
+		"ss-local": {
+			keys:   []AccessKey{alice},
+			format: ClientFormatSSLocal,
+			output: "{\n  \"server\": \"1.2.3.4\",\n  \"server_port\": 443,\n  \"local_address\": \"127.0.0.1\",\n  \"local_port\": 1080,\n" +
+				"  \"password\": \"secret\",\n  \"method\": \"chacha20-ietf-poly1305\",\n  \"mode\": \"tcp_and_udp\"\n}\n",
+		},
+		"ss-local-many": {keys: []AccessKey{alice, bob}, format: ClientFormatSSLocal, isErr: true},
+		"unknown":       {keys: []AccessKey{alice}, format: "v2ray", isErr: true},
+		"empty":         {format: ClientFormatSIP002, isErr: true},
+	}
+
+	for _, test := range tests {
+		b, err := ClientConfig(test.keys, "1.2.3.4", test.format)
+		assert.Equal(test.isErr, err != nil)
+		assert.Equal(test.output, string(b))
+	}
+}
+
+func TestClientConfigTags(t *testing.T) {
+	assert := assert.New(t)
+
+	tags := clientConfigTags([]AccessKey{
+		{ID: "1", Name: "alice"},
+		{ID: "2", Name: "bob"},
+		{ID: "3", Name: "bob"},
+		{ID: "4"},
+	})
+	assert.Equal([]string{"alice", "bob-2", "bob-3", "accesskey-4"}, tags)
+}
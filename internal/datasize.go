@@ -0,0 +1,66 @@
+package internal
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// dataUnits follows the Outline Manager which counts a GB as 1000^3 bytes,
+// the binary units are accepted as well.
+var dataUnits = map[string]float64{
+	"":    1,
+	"B":   1,
+	"KB":  1e3,
+	"MB":  1e6,
+	"GB":  1e9,
+	"TB":  1e12,
+	"KIB": 1 << 10,
+	"MIB": 1 << 20,
+	"GIB": 1 << 30,
+	"TIB": 1 << 40,
+}
+
+// ParseDataSize converts a human readable size such as "50GB" or "1.5 GiB" into bytes.
+func ParseDataSize(size string) (int, error) {
+	size = strings.ToUpper(strings.TrimSpace(size))
+	if size == "" {
+		return 0, fmt.Errorf("empty data size")
+	}
+
+	i := strings.IndexFunc(size, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	if i < 0 {
+		i = len(size)
+	}
+
+	value, err := strconv.ParseFloat(size[:i], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid data size %q", size)
+	}
+
+	unit, ok := dataUnits[strings.TrimSpace(size[i:])]
+	if !ok {
+		return 0, fmt.Errorf("invalid data size unit %q (B, KB, MB, GB, TB, KiB, MiB, GiB, TiB)", strings.TrimSpace(size[i:]))
+	}
+
+	return int(value * unit), nil
+}
+
+// FormatDataSize converts bytes into the decimal unit used by the Outline Manager.
+func FormatDataSize(bytes int) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+
+	value := float64(bytes)
+	unit := 0
+	for value >= 1000 && unit < len(units)-1 {
+		value /= 1000
+		unit++
+	}
+
+	if unit == 0 {
+		return fmt.Sprintf("%d B", bytes)
+	}
+	return fmt.Sprintf("%.2f %s", value, units[unit])
+}
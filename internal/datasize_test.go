@@ -0,0 +1,42 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDataSize(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]struct {
+		size  string
+		bytes int
+		isErr bool
+	}{
+		"bytes":   {size: "1024", bytes: 1024},
+		"gb":      {size: "50GB", bytes: 50000000000},
+		"lower":   {size: "20gb", bytes: 20000000000},
+		"space":   {size: "1.5 GiB", bytes: 1610612736},
+		"mb":      {size: "500MB", bytes: 500000000},
+		"empty":   {size: "", isErr: true},
+		"unit":    {size: "10XB", isErr: true},
+		"number":  {size: "GB", isErr: true},
+		"garbage": {size: "1.2.3GB", isErr: true},
+	}
+
+	for _, t := range tests {
+		bytes, err := ParseDataSize(t.size)
+		assert.Equal(t.isErr, err != nil, t.size)
+		assert.Equal(t.bytes, bytes, t.size)
+	}
+}
+
+func TestFormatDataSize(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("999 B", FormatDataSize(999))
+	assert.Equal("1.50 KB", FormatDataSize(1500))
+	assert.Equal("50.00 GB", FormatDataSize(50000000000))
+	assert.Equal("2000.00 TB", FormatDataSize(2000000000000000))
+}
@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDuration(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]struct {
+		duration time.Duration
+		isErr    bool
+	}{
+		"30d":   {duration: 30 * 24 * time.Hour},
+		"0d":    {duration: 0},
+		"72h":   {duration: 72 * time.Hour},
+		"1h30m": {duration: 90 * time.Minute},
+		" 7d ":  {duration: 7 * 24 * time.Hour},
+		"1.5d":  {isErr: true},
+		"-1d":   {isErr: true},
+		"-1h":   {isErr: true},
+		"d":     {isErr: true},
+		"":      {isErr: true},
+		"month": {isErr: true},
+	}
+
+	for value, test := range tests {
+		d, err := ParseDuration(value)
+		assert.Equal(test.isErr, err != nil, value)
+		assert.Equal(test.duration, d, value)
+	}
+}
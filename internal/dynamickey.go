@@ -0,0 +1,137 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// DynamicKey is the json an Outline client fetches for a ssconf:// access key,
+// so the server behind the key can change without handing out a new key.
+type DynamicKey struct {
+	Server     string `json:"server"`
+	ServerPort int    `json:"server_port"`
+	Password   string `json:"password"`
+	Method     string `json:"method"`
+}
+
+func NewDynamicKey(accessKey *AccessKey, server string) *DynamicKey {
+	return &DynamicKey{
+		Server:     server,
+		ServerPort: accessKey.Port,
+		Password:   accessKey.Password,
+		Method:     accessKey.Method,
+	}
+}
+
+func (k *DynamicKey) JSON() ([]byte, error) {
+	return json.MarshalIndent(k, "", "  ")
+}
+
+// NewDynamicKeyToken is the random name a dynamic key is published under.
+// The json holds the secret of the access key, so the name must not be
+// guessable from the key, and it's unique across regions and servers.
+func NewDynamicKeyToken() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// WriteDynamicKey writes the dynamic key as <token>.json in dir.
+func WriteDynamicKey(dynamicKey *DynamicKey, dir, token string) (string, error) {
+	b, err := dynamicKey.JSON()
+	if err != nil {
+		return "", err
+	}
+
+	if err = os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	path := filepath.Join(dir, token+".json")
+	if err = os.WriteFile(path, b, 0644); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// ParseBucketURL splits s3://bucket/prefix into the bucket and the prefix.
+func ParseBucketURL(bucketURL string) (string, string, error) {
+	u, err := url.Parse(bucketURL)
+	if err != nil || u.Scheme != "s3" || u.Host == "" {
+		return "", "", fmt.Errorf("invalid bucket %q (s3://bucket/prefix)", bucketURL)
+	}
+	return u.Host, strings.Trim(u.Path, "/"), nil
+}
+
+// UploadDynamicKey puts the dynamic key as <prefix>/<token>.json into the
+// bucket. endpoint points to an S3-compatible storage, empty is AWS S3.
+func UploadDynamicKey(ctx context.Context, cfg aws.Config, dynamicKey *DynamicKey, bucketURL, endpoint, token string) (string, error) {
+	bucket, prefix, err := ParseBucketURL(bucketURL)
+	if err != nil {
+		return "", err
+	}
+
+	b, err := dynamicKey.JSON()
+	if err != nil {
+		return "", err
+	}
+
+	client := s3.NewFromConfig(cfg, func(o *s3.Options) {
+		if endpoint != "" {
+			o.BaseEndpoint = aws.String(endpoint)
+			o.UsePathStyle = true
+		}
+	})
+
+	key := path.Join(prefix, token+".json")
+	_, err = client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:       aws.String(bucket),
+		Key:          aws.String(key),
+		Body:         bytes.NewReader(b),
+		ContentType:  aws.String("application/json"),
+		CacheControl: aws.String("no-cache"),
+	})
+	if err != nil {
+		return "", err
+	}
+	return key, nil
+}
+
+// BucketBaseURL is the https url the objects of the bucket are served from.
+func BucketBaseURL(bucketURL, endpoint, region string) (string, error) {
+	bucket, prefix, err := ParseBucketURL(bucketURL)
+	if err != nil {
+		return "", err
+	}
+
+	if endpoint != "" {
+		return strings.TrimSuffix(endpoint, "/") + "/" + path.Join(bucket, prefix), nil
+	}
+	return strings.TrimSuffix(fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, region, prefix), "/"), nil
+}
+
+// SSConfURL turns the https url the dynamic key <token>.json is served from into a ssconf:// access key.
+func SSConfURL(baseURL, token, tag string) (string, error) {
+	u, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/" + token + ".json")
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("invalid base url %q, the dynamic key must be served over https", baseURL)
+	}
+
+	u.Scheme = "ssconf"
+	u.Fragment = tag
+	return u.String(), nil
+}
@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDynamicKey(t *testing.T) {
+	assert := assert.New(t)
+
+	accessKey := &AccessKey{ID: "1", Name: "alice", Password: "secret", Port: 443, Method: "chacha20-ietf-poly1305"}
+	dynamicKey := NewDynamicKey(accessKey, "1.2.3.4")
+
+	token, err := NewDynamicKeyToken()
+	assert.NoError(err)
+	assert.Len(token, 32)
+	assert.NotContains(token, accessKey.Name)
+
+	other, err := NewDynamicKeyToken()
+	assert.NoError(err)
+	assert.NotEqual(token, other)
+
+	path, err := WriteDynamicKey(dynamicKey, filepath.Join(t.TempDir(), "keys"), token)
+	assert.NoError(err)
+	assert.Equal(token+".json", filepath.Base(path))
+
+	b, err := os.ReadFile(path)
+	assert.NoError(err)
+	assert.JSONEq(`{"server":"1.2.3.4","server_port":443,"password":"secret","method":"chacha20-ietf-poly1305"}`, string(b))
+}
+
+func TestDynamicKeyURL(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]struct {
+		bucket   string
+		endpoint string
+		base     string
+		ssconf   string
+		isErr    bool
+	}{
+		"s3": {
+			bucket: "s3://keys/outline/",
+			base:   "https://keys.s3.us-east-1.amazonaws.com/outline",
+			ssconf: "ssconf://keys.s3.us-east-1.amazonaws.com/outline/n4bQgYhMfWWaL-qgxVrQFa.json#alice",
+		},
+		"s3-root": {
+			bucket: "s3://keys",
+			base:   "https://keys.s3.us-east-1.amazonaws.com",
+			ssconf: "ssconf://keys.s3.us-east-1.amazonaws.com/n4bQgYhMfWWaL-qgxVrQFa.json#alice",
+		},
+		"compatible": {
+			bucket:   "s3://keys/outline",
+			endpoint: "https://minio.example.com/",
+			base:     "https://minio.example.com/keys/outline",
+			ssconf:   "ssconf://minio.example.com/keys/outline/n4bQgYhMfWWaL-qgxVrQFa.json#alice",
+		},
+		"http":   {bucket: "s3://keys", endpoint: "http://minio.example.com", base: "http://minio.example.com/keys", isErr: true},
+		"bucket": {bucket: "https://keys", isErr: true},
+	}
+
+	for name, t := range tests {
+		base, err := BucketBaseURL(t.bucket, t.endpoint, "us-east-1")
+		if t.base == "" {
+			assert.Error(err, name)
+			continue
+		}
+		assert.NoError(err, name)
+		assert.Equal(t.base, base, name)
+
+		ssconf, err := SSConfURL(base, "n4bQgYhMfWWaL-qgxVrQFa", "alice")
+		assert.Equal(t.isErr, err != nil, name)
+		assert.Equal(t.ssconf, ssconf, name)
+	}
+}
+
+func TestUploadDynamicKey(t *testing.T) {
+	assert := assert.New(t)
+
+	var method, path, body string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		method, path, body = r.Method, r.URL.Path, string(b)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := aws.Config{
+		Region:      "us-east-1",
+		Credentials: credentials.NewStaticCredentialsProvider("key", "secret", ""),
+	}
+	dynamicKey := &DynamicKey{Server: "1.2.3.4", ServerPort: 443, Password: "secret", Method: "aes-256-gcm"}
+
+	key, err := UploadDynamicKey(context.Background(), cfg, dynamicKey, "s3://keys/outline", server.URL, "n4bQgYhMfWWaL-qgxVrQFa")
+	assert.NoError(err)
+	assert.Equal("outline/n4bQgYhMfWWaL-qgxVrQFa.json", key)
+	assert.Equal(http.MethodPut, method)
+	assert.Equal("/keys/outline/n4bQgYhMfWWaL-qgxVrQFa.json", path)
+	assert.JSONEq(`{"server":"1.2.3.4","server_port":443,"password":"secret","method":"aes-256-gcm"}`, body)
+}
@@ -15,8 +15,45 @@ var (
 	ErrInvalidParams = errors.New("[err] invalid params")
 	// ErrUnknown is an error type to use when error reason doesn't know.
 	ErrUnknown = errors.New("[err] unknown")
+
+	// ErrOutlineNotFound is an error type to use when outline server doesn't know the requested resource.
+	ErrOutlineNotFound = errors.New("[err] outline resource not found")
+	// ErrOutlineLimitExceeded is an error type to use when outline server rejects a request over its limits.
+	ErrOutlineLimitExceeded = errors.New("[err] outline limit exceeded")
+	// ErrOutlineUnreachable is an error type to use when outline server can't be reached.
+	ErrOutlineUnreachable = errors.New("[err] outline server unreachable")
+	// ErrOutlineCertMismatch is an error type to use when outline server certificate doesn't match certSha256.
+	ErrOutlineCertMismatch = errors.New("[err] outline server certificate does not match certSha256")
 )
 
+// OutlineAPIError is returned when outline management api answers with an unexpected status.
+type OutlineAPIError struct {
+	StatusCode int
+	Method     string
+	Endpoint   string
+	Body       string
+}
+
+func (e *OutlineAPIError) Error() string {
+	if e.Body == "" {
+		return fmt.Sprintf("[err] outline api %s %s: status %d", e.Method, e.Endpoint, e.StatusCode)
+	}
+	return fmt.Sprintf("[err] outline api %s %s: status %d (%s)", e.Method, e.Endpoint, e.StatusCode, e.Body)
+}
+
+// Unwrap maps the status code to one of the outline sentinel errors, so callers can use errors.Is.
+func (e *OutlineAPIError) Unwrap() error {
+	switch e.StatusCode {
+	case 404:
+		return ErrOutlineNotFound
+	case 413, 429:
+		return ErrOutlineLimitExceeded
+	case 502, 503, 504:
+		return ErrOutlineUnreachable
+	}
+	return nil
+}
+
 // WrapError wraps error.
 func WrapError(err error) error {
 	if err != nil {
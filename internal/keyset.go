@@ -0,0 +1,148 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+const (
+	ImportCreated  = "created"
+	ImportExisting = "existing"
+	ImportFailed   = "failed"
+)
+
+// KeySet is a copy of the access keys of an outline server with their
+// secrets and local metadata. ImportKeySet recreates it on another server,
+// so the users keep their access keys.
+type KeySet struct {
+	Region     string                  `json:"region"`
+	ExportedAt time.Time               `json:"exportedAt"`
+	Keys       []AccessKey             `json:"keys"`
+	Metadata   map[string]*KeyMetadata `json:"metadata,omitempty"`
+}
+
+type ImportResult struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+func NewKeySet(region string, accessKeys *AccessKeys, registry *KeyRegistry, now time.Time) *KeySet {
+	keySet := &KeySet{
+		Region:     region,
+		ExportedAt: now.UTC(),
+		Keys:       accessKeys.Keys,
+		Metadata:   make(map[string]*KeyMetadata),
+	}
+
+	for _, v := range accessKeys.Keys {
+		if metadata := registry.Lookup(v.ID); metadata != nil {
+			keySet.Metadata[v.ID] = metadata
+		}
+	}
+	return keySet
+}
+
+func ReadKeySet(path string) (*KeySet, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var keySet KeySet
+	if err = json.Unmarshal(b, &keySet); err != nil {
+		return nil, fmt.Errorf("invalid key set %s: %w", path, err)
+	}
+	return &keySet, nil
+}
+
+// applyNameAndLimit gives the created access key the name and data limit of
+// template, older outline servers ignore them on creation.
+func applyNameAndLimit(ctx context.Context, client *OutlineClient, created, template *AccessKey) error {
+	if created.Name != template.Name {
+		if err := client.RenameAccessKey(ctx, created.ID, template.Name); err != nil {
+			return err
+		}
+		created.Name = template.Name
+	}
+
+	if template.DataLimit != nil && (created.DataLimit == nil || created.DataLimit.Bytes != template.DataLimit.Bytes) {
+		if err := client.AddDataLimitAccessKey(ctx, created.ID, template.DataLimit.Bytes); err != nil {
+			return err
+		}
+		created.DataLimit = template.DataLimit
+	}
+	return nil
+}
+
+// ImportKeySet creates the access keys of the key set under the same id,
+// secret, method, port, name and data limit, and copies their metadata into
+// registry. A key whose id already exists with the same secret is left as it is.
+func ImportKeySet(ctx context.Context, client *OutlineClient, existing *AccessKeys, registry *KeyRegistry, keySet *KeySet) []ImportResult {
+	results := make([]ImportResult, 0, len(keySet.Keys))
+
+	for i, v := range keySet.Keys {
+		result := ImportResult{ID: v.ID, Name: v.Name, Status: ImportFailed}
+
+		err := importAccessKey(ctx, client, existing, &keySet.Keys[i])
+		switch {
+		case errors.Is(err, errKeyExists):
+			result.Status = ImportExisting
+		case err != nil:
+			result.Error = err.Error()
+		default:
+			result.Status = ImportCreated
+		}
+
+		if result.Status != ImportFailed {
+			if metadata := keySet.Metadata[v.ID]; metadata != nil {
+				*registry.Get(v.ID) = *metadata
+			}
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+var errKeyExists = errors.New("access key exists")
+
+func importAccessKey(ctx context.Context, client *OutlineClient, existing *AccessKeys, accessKey *AccessKey) error {
+	if accessKey.ID == "" || accessKey.Password == "" {
+		return fmt.Errorf("the access key has no id or password")
+	}
+
+	if found := existing.Filter(accessKey.ID, ""); len(found.Keys) > 0 {
+		if found.Keys[0].Password != accessKey.Password {
+			return fmt.Errorf("the id %s is taken by another access key", accessKey.ID)
+		}
+		return errKeyExists
+	}
+
+	created, err := client.CreateAccessKey(ctx, &AccessKey{
+		ID:        accessKey.ID,
+		Name:      accessKey.Name,
+		Method:    accessKey.Method,
+		Password:  accessKey.Password,
+		Port:      accessKey.Port,
+		DataLimit: accessKey.DataLimit,
+	})
+	if err != nil {
+		return err
+	}
+
+	if created.Password != accessKey.Password {
+		// the users could not connect with their keys, don't leave a different key behind
+		if err = client.DeleteAccessKey(ctx, created.ID); err != nil {
+			return err
+		}
+		return fmt.Errorf("the outline server ignored the password, it's too old to import access keys")
+	}
+
+	return applyNameAndLimit(ctx, client, created, accessKey)
+}
@@ -0,0 +1,116 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeySet(t *testing.T) {
+	assert := assert.New(t)
+
+	registry := &KeyRegistry{Keys: map[string]*KeyMetadata{"1": {Owner: "alice"}, "9": {Owner: "deleted"}}}
+	accessKeys := &AccessKeys{Keys: []AccessKey{
+		{ID: "1", Name: "alice", Password: "a", Port: 443, Method: "aes-256-gcm"},
+		{ID: "2", Name: "bob", Password: "b", Port: 443, Method: "aes-256-gcm"},
+	}}
+
+	keySet := NewKeySet("us-east-1", accessKeys, registry, time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(map[string]*KeyMetadata{"1": {Owner: "alice"}}, keySet.Metadata)
+
+	b, err := json.Marshal(keySet)
+	assert.NoError(err)
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	assert.NoError(os.WriteFile(path, b, 0600))
+
+	read, err := ReadKeySet(path)
+	assert.NoError(err)
+	assert.Equal(keySet, read)
+
+	// an explicit limit of 0 bytes is kept
+	keySet.Keys[1].DataLimit = &DataLimit{}
+	b, err = json.Marshal(keySet)
+	assert.NoError(err)
+	assert.NoError(os.WriteFile(path, b, 0600))
+	read, err = ReadKeySet(path)
+	assert.NoError(err)
+	assert.Equal(&DataLimit{}, read.Keys[1].DataLimit)
+
+	assert.NoError(os.WriteFile(path, []byte("not json"), 0600))
+	_, err = ReadKeySet(path)
+	assert.Error(err)
+}
+
+func TestImportKeySet(t *testing.T) {
+	assert := assert.New(t)
+
+	var requests []string
+	server, outlineInfo := newOutlineTestServer(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, body))
+
+		switch r.URL.Path {
+		case "/secret/access-keys/1":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"1","name":"alice","password":"a","port":443,"method":"aes-256-gcm"}`))
+		case "/secret/access-keys/4":
+			if r.Method == http.MethodDelete {
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			// a server that ignores the password
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"4","password":"generated"}`))
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	})
+	defer server.Close()
+
+	client, err := NewOutlineClient(outlineInfo)
+	assert.NoError(err)
+
+	keySet := &KeySet{
+		Keys: []AccessKey{
+			{ID: "1", Name: "alice", Password: "a", Port: 443, Method: "aes-256-gcm", DataLimit: &DataLimit{Bytes: 100}},
+			{ID: "2", Name: "bob", Password: "b"},
+			{ID: "3", Name: "carol", Password: "c"},
+			{ID: "4", Name: "dave", Password: "d"},
+			{Name: "no-id", Password: "e"},
+		},
+		Metadata: map[string]*KeyMetadata{"1": {Owner: "alice"}, "2": {Owner: "bob"}, "3": {Owner: "carol"}},
+	}
+	existing := &AccessKeys{Keys: []AccessKey{
+		{ID: "2", Name: "bob", Password: "b"},
+		{ID: "3", Name: "someone", Password: "other"},
+	}}
+	registry := &KeyRegistry{Keys: make(map[string]*KeyMetadata)}
+
+	results := ImportKeySet(context.Background(), client, existing, registry, keySet)
+
+	statuses := make([]string, 0)
+	for _, v := range results {
+		statuses = append(statuses, v.Status)
+	}
+	assert.Equal([]string{ImportCreated, ImportExisting, ImportFailed, ImportFailed, ImportFailed}, statuses)
+	assert.Contains(results[2].Error, "taken by another access key")
+	assert.Contains(results[3].Error, "ignored the password")
+
+	assert.Equal(map[string]*KeyMetadata{"1": {Owner: "alice"}, "2": {Owner: "bob"}}, registry.Keys)
+
+	assert.Equal([]string{
+		`PUT /secret/access-keys/1 {"limit":{"bytes":100},"method":"aes-256-gcm","name":"alice","password":"a","port":443}`,
+		`PUT /secret/access-keys/1/data-limit {"limit":{"bytes":100}}`,
+		`PUT /secret/access-keys/4 {"name":"dave","password":"d"}`,
+		"DELETE /secret/access-keys/4 ",
+	}, requests)
+}
@@ -0,0 +1,91 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+type TransferMetrics struct {
+	BytesTransferredByUserId map[string]int `json:"bytesTransferredByUserId"`
+}
+
+// KeyUsage is the transferred bytes of an access key next to its data limit.
+type KeyUsage struct {
+	ID               string  `json:"id"`
+	Name             string  `json:"name"`
+	BytesTransferred int     `json:"bytesTransferred"`
+	DataLimit        *int    `json:"dataLimit,omitempty"`
+	PercentOfLimit   float64 `json:"percentOfLimit,omitempty"`
+}
+
+func (u *KeyUsage) GetDataLimit() string {
+	if u.DataLimit == nil {
+		return "-"
+	}
+	return FormatDataSize(*u.DataLimit)
+}
+
+func (u *KeyUsage) GetPercentOfLimit() string {
+	if u.DataLimit == nil || *u.DataLimit == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%%", u.PercentOfLimit)
+}
+
+// JoinKeyUsage pairs every access key with its transferred bytes.
+// Keys without traffic are reported with 0 bytes.
+func JoinKeyUsage(accessKeys *AccessKeys, metrics *TransferMetrics) []KeyUsage {
+	usages := make([]KeyUsage, 0, len(accessKeys.Keys))
+
+	for _, key := range accessKeys.Keys {
+		usage := KeyUsage{
+			ID:               key.ID,
+			Name:             key.Name,
+			BytesTransferred: metrics.BytesTransferredByUserId[key.ID],
+		}
+		if key.DataLimit != nil {
+			limit := key.DataLimit.Bytes
+			usage.DataLimit = &limit
+			if limit > 0 {
+				usage.PercentOfLimit = float64(usage.BytesTransferred) / float64(limit) * 100
+			}
+		}
+		usages = append(usages, usage)
+	}
+
+	return usages
+}
+
+// SortKeyUsage sorts by id, name, bytes or percent. bytes and percent put the heaviest user first.
+func SortKeyUsage(usages []KeyUsage, by string) error {
+	var less func(a, b KeyUsage) bool
+
+	switch by {
+	case "id":
+		less = func(a, b KeyUsage) bool { return lessID(a.ID, b.ID) }
+	case "name":
+		less = func(a, b KeyUsage) bool { return a.Name < b.Name }
+	case "bytes":
+		less = func(a, b KeyUsage) bool { return a.BytesTransferred > b.BytesTransferred }
+	case "percent":
+		less = func(a, b KeyUsage) bool { return a.PercentOfLimit > b.PercentOfLimit }
+	default:
+		return fmt.Errorf("invalid sort %q (id, name, bytes, percent)", by)
+	}
+
+	sort.SliceStable(usages, func(i, j int) bool {
+		return less(usages[i], usages[j])
+	})
+	return nil
+}
+
+// lessID compares outline access key ids, which are numeric strings.
+func lessID(a, b string) bool {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	if errA != nil || errB != nil {
+		return a < b
+	}
+	return x < y
+}
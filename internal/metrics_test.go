@@ -0,0 +1,55 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJoinKeyUsage(t *testing.T) {
+	assert := assert.New(t)
+
+	accessKeys := &AccessKeys{Keys: []AccessKey{
+		{ID: "2", Name: "bob", DataLimit: &DataLimit{Bytes: 1000}},
+		{ID: "10", Name: "alice"},
+		{ID: "3", Name: "carol", DataLimit: &DataLimit{Bytes: 100}},
+	}}
+	metrics := &TransferMetrics{BytesTransferredByUserId: map[string]int{
+		"2":  500,
+		"3":  90,
+		"99": 1,
+	}}
+
+	usages := JoinKeyUsage(accessKeys, metrics)
+	assert.Equal(3, len(usages))
+	assert.Equal(50.0, usages[0].PercentOfLimit)
+	assert.Equal(0, usages[1].BytesTransferred)
+	assert.Equal("-", usages[1].GetPercentOfLimit())
+	assert.Equal("90.0%", usages[2].GetPercentOfLimit())
+
+	tests := map[string]struct {
+		by    string
+		ids   []string
+		isErr bool
+	}{
+		"id":      {by: "id", ids: []string{"2", "3", "10"}},
+		"name":    {by: "name", ids: []string{"10", "2", "3"}},
+		"bytes":   {by: "bytes", ids: []string{"2", "3", "10"}},
+		"percent": {by: "percent", ids: []string{"3", "2", "10"}},
+		"invalid": {by: "size", isErr: true},
+	}
+
+	for _, t := range tests {
+		err := SortKeyUsage(usages, t.by)
+		assert.Equal(t.isErr, err != nil)
+		if err != nil {
+			continue
+		}
+
+		ids := make([]string, 0, len(usages))
+		for _, usage := range usages {
+			ids = append(ids, usage.ID)
+		}
+		assert.Equal(t.ids, ids, t.by)
+	}
+}
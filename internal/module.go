@@ -0,0 +1,186 @@
+package internal
+
+import (
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ModuleEmbedded = "embedded"
+	ModuleRegistry = "registry"
+	ModuleGit      = "git"
+
+	embeddedModuleRoot = "module/outline-vpn"
+	// embeddedModulePath is where the workspace keeps the embedded module, main.tf refers to it by path.
+	embeddedModulePath = "modules/outline-vpn"
+)
+
+// moduleFS is the outline-vpn terraform module of internal/module the binary was built with.
+//
+//go:embed all:module
+var moduleFS embed.FS
+
+// ModuleSource is where main.tf takes the outline-vpn module from: the
+// module embedded in the binary, a (private) registry or a git repository.
+type ModuleSource struct {
+	Type string `json:"type"`
+	// registry address or git url
+	Source string `json:"source,omitempty"`
+	// registry version or git ref
+	Version string `json:"version,omitempty"`
+}
+
+// HasEmbeddedModule reports whether the binary was built with the terraform module.
+func HasEmbeddedModule() bool {
+	return hasModule(moduleFS, embeddedModuleRoot)
+}
+
+// EmbeddedModuleVersion is the version in the VERSION file of the embedded
+// module, the version of the registry release when it was vendored from there.
+func EmbeddedModuleVersion() string {
+	b, err := fs.ReadFile(moduleFS, embeddedModuleRoot+"/VERSION")
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
+
+func hasModule(fsys fs.FS, root string) bool {
+	matches, err := fs.Glob(fsys, root+"/*.tf")
+	return err == nil && len(matches) > 0
+}
+
+// LoadModuleSource reads the module source configuration. Without one the
+// embedded module is used, or the public registry when the binary has none.
+func LoadModuleSource(path string) (*ModuleSource, error) {
+	module := &ModuleSource{Type: ModuleRegistry}
+	if HasEmbeddedModule() {
+		module.Type = ModuleEmbedded
+	}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return module, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(b, module); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return module, module.Validate()
+}
+
+func (m *ModuleSource) Save(path string) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+
+	buf, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, buf, 0600)
+}
+
+func (m *ModuleSource) Validate() error {
+	switch m.Type {
+	case ModuleEmbedded:
+		if !HasEmbeddedModule() {
+			return fmt.Errorf("this binary has no embedded terraform module, use %s or %s", ModuleRegistry, ModuleGit)
+		}
+	case ModuleRegistry:
+	case ModuleGit:
+		if m.Source == "" {
+			return fmt.Errorf("the git module source needs the url of the repository")
+		}
+	default:
+		return fmt.Errorf("invalid module source %q (%s, %s, %s)", m.Type, ModuleEmbedded, ModuleRegistry, ModuleGit)
+	}
+	return nil
+}
+
+// Attributes are the source and version of the module block in main.tf,
+// the version is empty for the sources that don't take one.
+func (m *ModuleSource) Attributes() (string, string) {
+	switch m.Type {
+	case ModuleEmbedded:
+		return "./" + embeddedModulePath, ""
+	case ModuleGit:
+		source := m.Source
+		if !strings.HasPrefix(source, "git::") {
+			source = "git::" + source
+		}
+		if m.Version != "" {
+			source = fmt.Sprintf("%s?ref=%s", source, m.Version)
+		}
+		return source, ""
+	}
+
+	source, version := m.Source, m.Version
+	if source == "" {
+		source = rootModule
+	}
+	if version == "" {
+		version = moduleVersion
+	}
+	return source, version
+}
+
+// CreateModuleDir writes the embedded module into the workspace, the other
+// sources are downloaded by terraform init.
+func CreateModuleDir(workSpacePath string, module *ModuleSource) error {
+	if module.Type != ModuleEmbedded {
+		return nil
+	}
+	return writeModule(moduleFS, embeddedModuleRoot, filepath.Join(workSpacePath, embeddedModulePath))
+}
+
+// writeModule replaces dir with the files of root in fsys.
+func writeModule(fsys fs.FS, root, dir string) error {
+	if !hasModule(fsys, root) {
+		return fmt.Errorf("there is no terraform module in %s", root)
+	}
+
+	if err := os.RemoveAll(dir); err != nil {
+		return err
+	}
+	return copyModule(fsys, root, dir)
+}
+
+// copyModule writes the files of root in fsys into dir over the ones there.
+func copyModule(fsys fs.FS, root, dir string) error {
+	if !hasModule(fsys, root) {
+		return fmt.Errorf("there is no terraform module in %s", root)
+	}
+
+	return fs.WalkDir(fsys, root, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		target := filepath.Join(dir, filepath.FromSlash(strings.TrimPrefix(name, root)))
+		if d.IsDir() {
+			return os.MkdirAll(target, 0755)
+		}
+
+		b, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return err
+		}
+
+		mode := os.FileMode(0644)
+		if path.Ext(name) == ".sh" {
+			mode = 0755
+		}
+		return os.WriteFile(target, b, mode)
+	})
+}
@@ -0,0 +1,150 @@
+locals {
+  name     = "govpn-ec2-${var.aws_region}"
+  my_cidr  = "${chomp(data.http.my_ip.response_body)}/32"
+  pem_path = pathexpand("~/.ssh/vpn_ec2_key.pem")
+
+  install_script = "${path.module}/install_server.sh"
+
+  # the files the outline-vpn cli reads in the workspace
+  access_txt   = "${path.root}/access.txt"
+  outline_json = "${path.root}/outline.json"
+  access_key   = "${path.root}/access_key.json"
+}
+
+# only the public ip of the user who provisions the server may reach it
+data "http" "my_ip" {
+  url = "http://ipv4.icanhazip.com"
+}
+
+data "aws_subnet" "default" {
+  availability_zone = var.availability_zone
+  default_for_az    = true
+}
+
+resource "aws_security_group" "outline" {
+  name        = "govpn-sg-${var.aws_region}"
+  description = "outline-vpn server"
+  vpc_id      = data.aws_subnet.default.vpc_id
+
+  ingress {
+    description = "ssh"
+    protocol    = "tcp"
+    from_port   = 22
+    to_port     = 22
+    cidr_blocks = [local.my_cidr]
+  }
+
+  ingress {
+    description = "outline management api"
+    protocol    = "tcp"
+    from_port   = var.management_port
+    to_port     = var.management_port
+    cidr_blocks = [local.my_cidr]
+  }
+
+  ingress {
+    description = "outline access keys (tcp)"
+    protocol    = "tcp"
+    from_port   = var.vpn_port
+    to_port     = var.vpn_port
+    cidr_blocks = [local.my_cidr]
+  }
+
+  ingress {
+    description = "outline access keys (udp)"
+    protocol    = "udp"
+    from_port   = var.vpn_port
+    to_port     = var.vpn_port
+    cidr_blocks = [local.my_cidr]
+  }
+
+  egress {
+    protocol    = "-1"
+    from_port   = 0
+    to_port     = 0
+    cidr_blocks = ["0.0.0.0/0"]
+  }
+
+  tags = {
+    Name = "govpn-sg-${var.aws_region}"
+  }
+}
+
+resource "local_sensitive_file" "pem" {
+  filename        = local.pem_path
+  content         = var.private_key_pem
+  file_permission = "0600"
+}
+
+resource "aws_instance" "outline" {
+  ami                         = var.ec2_ami
+  instance_type               = var.instance_type
+  availability_zone           = var.availability_zone
+  subnet_id                   = data.aws_subnet.default.id
+  key_name                    = var.key_name
+  vpc_security_group_ids      = [aws_security_group.outline.id]
+  associate_public_ip_address = true
+
+  tags = {
+    Name = local.name
+  }
+
+  connection {
+    type        = "ssh"
+    host        = self.public_ip
+    user        = "ec2-user"
+    private_key = var.private_key_pem
+  }
+
+  lifecycle {
+    precondition {
+      condition     = fileexists(local.install_script)
+      error_message = "install_server.sh of the outline server is not vendored in the module, run `scripts/deploy.sh installer <commit>`."
+    }
+  }
+
+  # the install script is vendored at a commit of Jigsaw-Code/outline-server, nothing is downloaded from a branch
+  provisioner "file" {
+    source      = local.install_script
+    destination = "/tmp/install_server.sh"
+  }
+
+  provisioner "remote-exec" {
+    inline = [
+      "sudo yum install -y docker",
+      "sudo systemctl enable --now docker",
+      "sudo bash /tmp/install_server.sh --hostname ${self.public_ip} --api-port ${var.management_port} --keys-port ${var.vpn_port}",
+    ]
+  }
+}
+
+# fetch the management api of the server into outline.json and create the first access key
+resource "terraform_data" "outline" {
+  triggers_replace = [aws_instance.outline.id]
+
+  provisioner "local-exec" {
+    command = <<-EOT
+      set -e
+      rsync --rsync-path="sudo rsync" \
+        -e "ssh -i ${local_sensitive_file.pem.filename} -o StrictHostKeyChecking=no -o UserKnownHostsFile=/dev/null" \
+        ec2-user@${aws_instance.outline.public_ip}:/opt/outline/access.txt "${local.access_txt}"
+      jq -Rn --argjson management ${var.management_port} --argjson vpn ${var.vpn_port} \
+        '[inputs | capture("^(?<key>[^:]+):(?<value>.*)$")] | from_entries
+         | {ManagementUdpPort: $management, VpnTcpUdpPort: $vpn, ApiUrl: .apiUrl, CertSha256: .certSha256}' \
+        < "${local.access_txt}" > "${local.outline_json}"
+      curl -fsSk -X POST "$(jq -r .ApiUrl "${local.outline_json}")/access-keys" > "${local.access_key}"
+      rm -f "${local.access_txt}"
+    EOT
+  }
+}
+
+data "local_file" "access_key" {
+  filename   = local.access_key
+  depends_on = [terraform_data.outline]
+}
+
+# keeps outline.json in the state, `outline-vpn backend pull` writes it on another machine
+data "local_file" "outline" {
+  filename   = local.outline_json
+  depends_on = [terraform_data.outline]
+}
@@ -0,0 +1,14 @@
+output "Region" {
+  description = "Region of the outline server"
+  value       = var.aws_region
+}
+
+output "PublicIP" {
+  description = "Public ip of the EC2 instance"
+  value       = aws_instance.outline.public_ip
+}
+
+output "OutlineClientAccessKey" {
+  description = "The first access key, paste it into the Outline Client App"
+  value       = jsondecode(data.local_file.access_key.content).accessUrl
+}
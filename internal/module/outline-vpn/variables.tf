@@ -0,0 +1,48 @@
+variable "aws_region" {
+  description = "Region of the outline server"
+  type        = string
+}
+
+variable "availability_zone" {
+  description = "Availability zone of the default subnet the EC2 instance runs in"
+  type        = string
+}
+
+variable "ec2_ami" {
+  description = "Amazon Linux 2 image of the EC2 instance"
+  type        = string
+}
+
+variable "instance_type" {
+  description = "Instance type of the EC2 instance"
+  type        = string
+}
+
+variable "key_name" {
+  description = "Key pair of the EC2 instance"
+  type        = string
+}
+
+variable "private_key_openssh" {
+  description = "Private key of the key pair in the OpenSSH format"
+  type        = string
+  sensitive   = true
+}
+
+variable "private_key_pem" {
+  description = "Private key of the key pair in the PEM format, used to install the outline server"
+  type        = string
+  sensitive   = true
+}
+
+variable "management_port" {
+  description = "TCP port of the outline management api"
+  type        = number
+  default     = 8081
+}
+
+variable "vpn_port" {
+  description = "TCP and UDP port of the access keys"
+  type        = number
+  default     = 443
+}
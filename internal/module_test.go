@@ -0,0 +1,86 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModuleSourceAttributes(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]struct {
+		input   *ModuleSource
+		source  string
+		version string
+	}{
+		"embedded":         {input: &ModuleSource{Type: ModuleEmbedded}, source: "./modules/outline-vpn"},
+		"registry":         {input: &ModuleSource{Type: ModuleRegistry}, source: rootModule, version: moduleVersion},
+		"registry-pinned":  {input: &ModuleSource{Type: ModuleRegistry, Version: "1.0.1"}, source: rootModule, version: "1.0.1"},
+		"registry-private": {input: &ModuleSource{Type: ModuleRegistry, Source: "app.terraform.io/acme/outline-vpn/aws", Version: "2.0.0"}, source: "app.terraform.io/acme/outline-vpn/aws", version: "2.0.0"},
+		"git":              {input: &ModuleSource{Type: ModuleGit, Source: "https://git.example.com/outline-vpn.git"}, source: "git::https://git.example.com/outline-vpn.git"},
+		"git-ref":          {input: &ModuleSource{Type: ModuleGit, Source: "git::ssh://git@git.example.com/outline-vpn.git", Version: "v1.0.0"}, source: "git::ssh://git@git.example.com/outline-vpn.git?ref=v1.0.0"},
+	}
+
+	for name, test := range tests {
+		source, version := test.input.Attributes()
+		assert.Equal(test.source, source, name)
+		assert.Equal(test.version, version, name)
+	}
+
+	assert.NoError((&ModuleSource{Type: ModuleEmbedded}).Validate())
+	assert.Error((&ModuleSource{Type: ModuleGit}).Validate())
+	assert.Error((&ModuleSource{Type: "s3"}).Validate())
+	assert.NoError((&ModuleSource{Type: ModuleRegistry}).Validate())
+}
+
+func TestModuleSourceSave(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "module.json")
+
+	// the module of internal/module is embedded and the default
+	assert.True(HasEmbeddedModule())
+	assert.NotEmpty(EmbeddedModuleVersion())
+	module, err := LoadModuleSource(path)
+	assert.NoError(err)
+	assert.Equal(ModuleEmbedded, module.Type)
+
+	git := &ModuleSource{Type: ModuleGit, Source: "https://git.example.com/outline-vpn.git", Version: "v1.0.0"}
+	assert.NoError(git.Save(path))
+	module, err = LoadModuleSource(path)
+	assert.NoError(err)
+	assert.Equal(git, module)
+}
+
+func TestWriteModule(t *testing.T) {
+	assert := assert.New(t)
+
+	fsys := fstest.MapFS{
+		"module/outline-vpn/main.tf":            {Data: []byte("resource \"aws_instance\" \"outline\" {}\n")},
+		"module/outline-vpn/scripts/install.sh": {Data: []byte("#!/bin/sh\n")},
+		"module/empty/README.md":                {Data: []byte("no module\n")},
+	}
+
+	dir := filepath.Join(t.TempDir(), "modules", "outline-vpn")
+	assert.NoError(os.MkdirAll(dir, 0755))
+	assert.NoError(os.WriteFile(filepath.Join(dir, "stale.tf"), nil, 0644))
+
+	assert.NoError(writeModule(fsys, "module/outline-vpn", dir))
+
+	b, err := os.ReadFile(filepath.Join(dir, "main.tf"))
+	assert.NoError(err)
+	assert.Equal("resource \"aws_instance\" \"outline\" {}\n", string(b))
+
+	info, err := os.Stat(filepath.Join(dir, "scripts", "install.sh"))
+	assert.NoError(err)
+	assert.Equal(os.FileMode(0755), info.Mode().Perm())
+
+	_, err = os.Stat(filepath.Join(dir, "stale.tf"))
+	assert.True(os.IsNotExist(err))
+
+	assert.Error(writeModule(fsys, "module/empty", dir))
+}
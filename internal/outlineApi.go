@@ -1,15 +1,74 @@
 package internal
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/go-resty/resty/v2"
 )
 
+const (
+	defaultOutlineRetryCount   = 3
+	defaultOutlineRetryWait    = time.Second
+	defaultOutlineRetryMaxWait = 10 * time.Second
+)
+
+// OutlineClient talks to the management API of a single Outline server.
+// The server uses a self-signed certificate, so instead of the usual chain
+// verification the leaf certificate is pinned to OutlineInfo.CertSha256.
+type OutlineClient struct {
+	client  *resty.Client
+	timeout time.Duration
+	verbose io.Writer
+}
+
+type OutlineClientOption func(*OutlineClient)
+
+// WithTimeout bounds every call of the client, retries included. 0 waits forever.
+func WithTimeout(timeout time.Duration) OutlineClientOption {
+	return func(c *OutlineClient) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetry retries an idempotent call count times, waiting with an
+// exponential backoff from wait up to maxWait between the attempts.
+func WithRetry(count int, wait, maxWait time.Duration) OutlineClientOption {
+	return func(c *OutlineClient) {
+		c.client.SetRetryCount(count).
+			SetRetryWaitTime(wait).
+			SetRetryMaxWaitTime(maxWait)
+	}
+}
+
+// WithVerbose reports the status of every attempt to w.
+func WithVerbose(w io.Writer) OutlineClientOption {
+	return func(c *OutlineClient) {
+		c.verbose = w
+	}
+}
+
+// discardLogger silences resty, the attempts are reported by WithVerbose.
+type discardLogger struct{}
+
+func (discardLogger) Errorf(string, ...interface{}) {}
+func (discardLogger) Warnf(string, ...interface{})  {}
+func (discardLogger) Debugf(string, ...interface{}) {}
+
 func checkOutlineJsonExists(list []string) []string {
 	workspace := make([]string, 0)
 
@@ -44,156 +103,371 @@ func ValidateOutlineJson(ctx context.Context, terraformVersion, _defaultTerrafor
 	return list, nil
 }
 
-func CreateAccessKey(region string) (*AccessKey, error) {
-	apiURL, err := GetApiURL(region)
-	if err != nil {
-		return nil, err
+func NewOutlineClient(outlineInfo *OutlineInfo, opts ...OutlineClientOption) (*OutlineClient, error) {
+	if outlineInfo == nil || outlineInfo.ApiURL == "" || outlineInfo.CertSha256 == "" {
+		return nil, WrapError(ErrInvalidParams)
+	}
+
+	fingerprint, err := hex.DecodeString(outlineInfo.CertSha256)
+	if err != nil || len(fingerprint) != sha256.Size {
+		return nil, fmt.Errorf("invalid certSha256 %q", outlineInfo.CertSha256)
 	}
-	url := fmt.Sprintf("%s/%s", apiURL, "access-keys")
 
 	client := resty.New()
-	client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
-	resp, err := client.R().
-		SetHeader("Content-Type", "application/json").
-		Post(url)
+	client.SetTLSClientConfig(&tls.Config{
+		// the chain can't be verified for a self-signed certificate,
+		// VerifyPeerCertificate checks the pinned fingerprint instead.
+		InsecureSkipVerify:    true,
+		VerifyPeerCertificate: pinCertSha256(fingerprint),
+	})
+	client.SetBaseURL(strings.TrimSuffix(outlineInfo.ApiURL, "/"))
+	client.SetHeader("Content-Type", "application/json")
+	client.SetLogger(discardLogger{})
+
+	c := &OutlineClient{client: client}
+	client.AddRetryCondition(c.retryCondition)
+	c.logAttempts(client)
+
+	WithRetry(defaultOutlineRetryCount, defaultOutlineRetryWait, defaultOutlineRetryMaxWait)(c)
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c, nil
+}
+
+func NewOutlineClientFromRegion(region string, opts ...OutlineClientOption) (*OutlineClient, error) {
+	outlineInfo, err := readOutlineInfo(region)
 	if err != nil {
 		return nil, err
 	}
 
-	if resp.StatusCode() == 201 {
-		var result AccessKey
-		err := json.Unmarshal(resp.Body(), &result)
-		if err != nil {
-			return nil, err
-		}
+	return NewOutlineClient(outlineInfo, opts...)
+}
 
-		return &result, err
+// retryCondition retries the idempotent methods when the server can't be
+// reached or answers 5xx, e.g. while the EC2 instance is still booting.
+func (c *OutlineClient) retryCondition(resp *resty.Response, err error) bool {
+	if resp == nil || resp.Request == nil {
+		return false
 	}
 
-	return nil, err
+	switch resp.Request.Method {
+	case resty.MethodGet, resty.MethodPut, resty.MethodDelete:
+		return (err != nil && !errors.Is(err, ErrOutlineCertMismatch)) || resp.StatusCode() >= 500
+	}
+	return false
 }
 
-func GetAccessKeys(region string) (*AccessKeys, error) {
-	apiURL, err := GetApiURL(region)
-	if err != nil {
-		return nil, err
+// logAttempts reports every attempt of a request to the verbose writer from
+// the hooks of resty, which run with retries disabled as well: a response
+// after it's read, a failure that is retried before the retry and the last
+// failure when the request gives up.
+func (c *OutlineClient) logAttempts(client *resty.Client) {
+	client.OnAfterResponse(func(_ *resty.Client, resp *resty.Response) error {
+		c.logAttempt(resp.Request, resp.Status(), resp.Time(), c.retryCondition(resp, nil))
+		return nil
+	})
+
+	client.AddRetryHook(func(resp *resty.Response, err error) {
+		if err != nil && resp != nil && resp.Request != nil && resp.Request.Attempt <= client.RetryCount {
+			c.logAttempt(resp.Request, err.Error(), time.Since(resp.Request.Time), true)
+		}
+	})
+
+	client.OnError(func(req *resty.Request, err error) {
+		var respErr *resty.ResponseError
+		if errors.As(err, &respErr) {
+			err = respErr.Err
+		}
+		c.logAttempt(req, err.Error(), time.Since(req.Time), false)
+	})
+}
+
+func (c *OutlineClient) logAttempt(req *resty.Request, status string, elapsed time.Duration, retry bool) {
+	if c.verbose == nil {
+		return
 	}
-	url := fmt.Sprintf("%s/%s", apiURL, "access-keys")
 
-	client := resty.New()
-	client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
-	resp, err := client.R().
-		SetHeader("Content-Type", "application/json").
-		Get(url)
-	if err != nil {
-		return nil, err
+	next := ""
+	if retry && req.Attempt <= c.client.RetryCount {
+		next = ", retrying"
 	}
+	fmt.Fprintf(c.verbose, "[attempt %d/%d] %s %s: %s (%s)%s\n",
+		req.Attempt, c.client.RetryCount+1, req.Method, strings.TrimPrefix(req.URL, c.client.BaseURL), status, elapsed.Round(time.Millisecond), next)
+}
 
-	var accessKeys AccessKeys
-	if resp.StatusCode() == 200 {
-		err = json.Unmarshal(resp.Body(), &accessKeys)
-		if err != nil {
-			return nil, err
+func pinCertSha256(fingerprint []byte) func([][]byte, [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return fmt.Errorf("outline server did not present a certificate")
 		}
-	}
 
-	return &accessKeys, nil
+		sum := sha256.Sum256(rawCerts[0])
+		if !bytes.Equal(sum[:], fingerprint) {
+			return fmt.Errorf("%w %X", ErrOutlineCertMismatch, fingerprint)
+		}
+		return nil
+	}
 }
 
-func DeleteAccessKey(region string, id string) error {
-	apiURL, err := GetApiURL(region)
-	if err != nil {
-		return err
+// request sends a single call to the management api. A status other than
+// expected is returned as *OutlineAPIError, a transport failure is wrapped
+// with ErrOutlineUnreachable.
+func (c *OutlineClient) request(ctx context.Context, method, endpoint string, body interface{}, expected int, result interface{}) error {
+	if c.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.timeout)
+		defer cancel()
 	}
-	url := fmt.Sprintf("%s/%s/%s", apiURL, "access-keys", id)
 
-	client := resty.New()
-	client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
+	req := c.client.R().SetContext(ctx)
+	if body != nil {
+		req.SetBody(body)
+	}
 
-	resp, err := client.R().Delete(url)
+	resp, err := req.Execute(method, endpoint)
 	if err != nil {
-		return err
+		if errors.Is(err, ErrOutlineCertMismatch) {
+			return err
+		}
+		return fmt.Errorf("%w: %s", ErrOutlineUnreachable, err)
 	}
 
-	if resp.StatusCode() == 204 {
-		return nil
+	if resp.StatusCode() != expected {
+		return &OutlineAPIError{
+			StatusCode: resp.StatusCode(),
+			Method:     method,
+			Endpoint:   endpoint,
+			Body:       strings.TrimSpace(string(resp.Body())),
+		}
 	}
-	return err
+
+	if result != nil {
+		if err := json.Unmarshal(resp.Body(), result); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func RenameAccessKey(region string, id int, name string) error {
-	apiURL, err := GetApiURL(region)
-	if err != nil {
-		return err
+// CreateAccessKey creates an access key from the name, method, password,
+// port and data limit set on template, the zero values are left to the server.
+// With template.ID set the key is created under that id.
+func (c *OutlineClient) CreateAccessKey(ctx context.Context, template *AccessKey) (*AccessKey, error) {
+	var (
+		method   = resty.MethodPost
+		endpoint = "/access-keys"
+		postData = make(map[string]interface{})
+	)
+
+	if template != nil {
+		if template.ID != "" {
+			method = resty.MethodPut
+			endpoint = fmt.Sprintf("/access-keys/%s", template.ID)
+		}
+		if template.Name != "" {
+			postData["name"] = template.Name
+		}
+		if template.Method != "" {
+			postData["method"] = template.Method
+		}
+		if template.Password != "" {
+			postData["password"] = template.Password
+		}
+		if template.Port != 0 {
+			postData["port"] = template.Port
+		}
+		if template.DataLimit != nil {
+			postData["limit"] = template.DataLimit
+		}
 	}
-	url := fmt.Sprintf("%s/%s/%d/%s", apiURL, "access-keys", id, "name")
 
-	client := resty.New()
-	client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
+	var accessKey AccessKey
+	if err := c.request(ctx, method, endpoint, postData, 201, &accessKey); err != nil {
+		return nil, err
+	}
+
+	return &accessKey, nil
+}
+
+func (c *OutlineClient) GetAccessKeys(ctx context.Context) (*AccessKeys, error) {
+	var accessKeys AccessKeys
+	if err := c.request(ctx, resty.MethodGet, "/access-keys", nil, 200, &accessKeys); err != nil {
+		return nil, err
+	}
 
+	return &accessKeys, nil
+}
+
+func (c *OutlineClient) DeleteAccessKey(ctx context.Context, id string) error {
+	return c.request(ctx, resty.MethodDelete, fmt.Sprintf("/access-keys/%s", id), nil, 204, nil)
+}
+
+func (c *OutlineClient) RenameAccessKey(ctx context.Context, id string, name string) error {
 	putData := map[string]string{
 		"name": name,
 	}
-	resp, err := client.R().
-		SetHeader("Content-Type", "application/json").
-		SetBody(putData).
-		Put(url)
-	if err != nil {
-		return err
+	return c.request(ctx, resty.MethodPut, fmt.Sprintf("/access-keys/%s/name", id), putData, 204, nil)
+}
+
+func (c *OutlineClient) AddDataLimitAccessKey(ctx context.Context, id string, limit int) error {
+	putData := map[string]map[string]int{
+		"limit": {
+			"bytes": limit,
+		},
 	}
+	return c.request(ctx, resty.MethodPut, fmt.Sprintf("/access-keys/%s/data-limit", id), putData, 204, nil)
+}
 
-	if resp.StatusCode() == 204 {
-		return nil
+func (c *OutlineClient) DeleteDataLimitAccessKey(ctx context.Context, id string) error {
+	return c.request(ctx, resty.MethodDelete, fmt.Sprintf("/access-keys/%s/data-limit", id), nil, 204, nil)
+}
+
+func (c *OutlineClient) GetServer(ctx context.Context) (*OutlineServer, error) {
+	var server OutlineServer
+	if err := c.request(ctx, resty.MethodGet, "/server", nil, 200, &server); err != nil {
+		return nil, err
 	}
-	return err
+
+	return &server, nil
 }
 
-func AddDataLimitAccessKey(region string, id int, limit int) error {
-	apiURL, err := GetApiURL(region)
-	if err != nil {
-		return err
+func (c *OutlineClient) RenameServer(ctx context.Context, name string) error {
+	putData := map[string]string{
+		"name": name,
 	}
-	url := fmt.Sprintf("%s/%s/%d/%s", apiURL, "access-keys", id, "data-limit")
+	return c.request(ctx, resty.MethodPut, "/name", putData, 204, nil)
+}
 
-	client := resty.New()
-	client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
+func (c *OutlineClient) SetHostnameForAccessKeys(ctx context.Context, hostname string) error {
+	putData := map[string]string{
+		"hostname": hostname,
+	}
+	return c.request(ctx, resty.MethodPut, "/server/hostname-for-access-keys", putData, 204, nil)
+}
 
+func (c *OutlineClient) SetPortForNewAccessKeys(ctx context.Context, port int) error {
+	putData := map[string]int{
+		"port": port,
+	}
+	return c.request(ctx, resty.MethodPut, "/server/port-for-new-access-keys", putData, 204, nil)
+}
+
+func (c *OutlineClient) AddDefaultDataLimit(ctx context.Context, limit int) error {
 	putData := map[string]map[string]int{
 		"limit": {
 			"bytes": limit,
 		},
 	}
-	resp, err := client.R().
-		SetHeader("Content-Type", "application/json").
-		SetBody(putData).
-		Put(url)
+	return c.request(ctx, resty.MethodPut, "/server/access-key-data-limit", putData, 204, nil)
+}
+
+func (c *OutlineClient) DeleteDefaultDataLimit(ctx context.Context) error {
+	return c.request(ctx, resty.MethodDelete, "/server/access-key-data-limit", nil, 204, nil)
+}
+
+func (c *OutlineClient) SetMetricsEnabled(ctx context.Context, enabled bool) error {
+	putData := map[string]bool{
+		"metricsEnabled": enabled,
+	}
+	return c.request(ctx, resty.MethodPut, "/metrics/enabled", putData, 204, nil)
+}
+
+func (c *OutlineClient) GetTransferMetrics(ctx context.Context) (*TransferMetrics, error) {
+	var metrics TransferMetrics
+	if err := c.request(ctx, resty.MethodGet, "/metrics/transfer", nil, 200, &metrics); err != nil {
+		return nil, err
+	}
+
+	return &metrics, nil
+}
+
+func CreateAccessKey(region string) (*AccessKey, error) {
+	client, err := NewOutlineClientFromRegion(region)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.CreateAccessKey(context.Background(), nil)
+}
+
+func GetAccessKeys(region string) (*AccessKeys, error) {
+	client, err := NewOutlineClientFromRegion(region)
+	if err != nil {
+		return nil, err
+	}
+
+	return client.GetAccessKeys(context.Background())
+}
+
+func DeleteAccessKey(region string, id string) error {
+	client, err := NewOutlineClientFromRegion(region)
 	if err != nil {
 		return err
 	}
 
-	if resp.StatusCode() == 204 {
-		return nil
+	return client.DeleteAccessKey(context.Background(), id)
+}
+
+func RenameAccessKey(region string, id int, name string) error {
+	client, err := NewOutlineClientFromRegion(region)
+	if err != nil {
+		return err
 	}
-	return err
+
+	return client.RenameAccessKey(context.Background(), strconv.Itoa(id), name)
 }
 
-func DeleteDataLimitAccessKey(region string, id int) error {
-	apiURL, err := GetApiURL(region)
+func AddDataLimitAccessKey(region string, id int, limit int) error {
+	client, err := NewOutlineClientFromRegion(region)
 	if err != nil {
 		return err
 	}
-	url := fmt.Sprintf("%s/%s/%d/%s", apiURL, "access-keys", id, "data-limit")
 
-	client := resty.New()
-	client.SetTLSClientConfig(&tls.Config{InsecureSkipVerify: true})
+	return client.AddDataLimitAccessKey(context.Background(), strconv.Itoa(id), limit)
+}
 
-	resp, err := client.R().Delete(url)
+func DeleteDataLimitAccessKey(region string, id int) error {
+	client, err := NewOutlineClientFromRegion(region)
 	if err != nil {
 		return err
 	}
 
-	if resp.StatusCode() == 204 {
-		return nil
+	return client.DeleteDataLimitAccessKey(context.Background(), strconv.Itoa(id))
+}
+
+// RegionAccessKeys is the access key listing of the outline server in one region.
+type RegionAccessKeys struct {
+	Region     string
+	AccessKeys *AccessKeys
+	Err        error
+}
+
+// GetAccessKeysAllRegions lists the access keys of every outline server
+// concurrently, sorted by region. A failing server only sets the Err of its
+// own listing.
+func GetAccessKeysAllRegions(ctx context.Context, clients map[string]*OutlineClient) []RegionAccessKeys {
+	var (
+		wg      sync.WaitGroup
+		results = make([]RegionAccessKeys, 0, len(clients))
+	)
+
+	for region := range clients {
+		results = append(results, RegionAccessKeys{Region: region})
 	}
-	return err
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Region < results[j].Region
+	})
+
+	for i := range results {
+		wg.Add(1)
+		go func(result *RegionAccessKeys) {
+			defer wg.Done()
+			result.AccessKeys, result.Err = clients[result.Region].GetAccessKeys(ctx)
+		}(&results[i])
+	}
+	wg.Wait()
+
+	return results
 }
@@ -0,0 +1,336 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newOutlineTestServer(handler http.HandlerFunc) (*httptest.Server, *OutlineInfo) {
+	server := httptest.NewTLSServer(handler)
+	sum := sha256.Sum256(server.Certificate().Raw)
+
+	return server, &OutlineInfo{
+		ApiURL:     server.URL + "/secret",
+		CertSha256: hex.EncodeToString(sum[:]),
+	}
+}
+
+func TestNewOutlineClient(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]struct {
+		outlineInfo *OutlineInfo
+		isErr       bool
+	}{
+		"nil":           {outlineInfo: nil, isErr: true},
+		"empty":         {outlineInfo: &OutlineInfo{}, isErr: true},
+		"invalid-sha":   {outlineInfo: &OutlineInfo{ApiURL: "https://127.0.0.1", CertSha256: "zz"}, isErr: true},
+		"short-sha":     {outlineInfo: &OutlineInfo{ApiURL: "https://127.0.0.1", CertSha256: "ABCD"}, isErr: true},
+		"success-upper": {outlineInfo: &OutlineInfo{ApiURL: "https://127.0.0.1", CertSha256: "E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855"}, isErr: false},
+	}
+
+	for _, t := range tests {
+		_, err := NewOutlineClient(t.outlineInfo)
+		assert.Equal(t.isErr, err != nil)
+	}
+}
+
+func TestOutlineClientPinning(t *testing.T) {
+	assert := assert.New(t)
+
+	server, outlineInfo := newOutlineTestServer(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal("/secret/access-keys", r.URL.Path)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"accessKeys":[{"id":"0","name":"alice","accessUrl":"ss://example"}]}`))
+	})
+	defer server.Close()
+
+	client, err := NewOutlineClient(outlineInfo)
+	assert.NoError(err)
+
+	accessKeys, err := client.GetAccessKeys(context.Background())
+	assert.NoError(err)
+	assert.Equal(1, len(accessKeys.Keys))
+	assert.Equal("alice", accessKeys.Keys[0].Name)
+
+	outlineInfo.CertSha256 = "E3B0C44298FC1C149AFBF4C8996FB92427AE41E4649B934CA495991B7852B855"
+	client, err = NewOutlineClient(outlineInfo)
+	assert.NoError(err)
+
+	_, err = client.GetAccessKeys(context.Background())
+	assert.True(errors.Is(err, ErrOutlineCertMismatch))
+}
+
+func TestOutlineClientErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]struct {
+		status int
+		target error
+	}{
+		"not-found":   {status: 404, target: ErrOutlineNotFound},
+		"too-many":    {status: 429, target: ErrOutlineLimitExceeded},
+		"unavailable": {status: 503, target: ErrOutlineUnreachable},
+		"bad-request": {status: 400, target: nil},
+	}
+
+	for name, tt := range tests {
+		server, outlineInfo := newOutlineTestServer(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+			w.Write([]byte(name))
+		})
+
+		client, err := NewOutlineClient(outlineInfo, WithRetry(1, time.Millisecond, time.Millisecond))
+		assert.NoError(err)
+
+		err = client.DeleteAccessKey(context.Background(), "1")
+		server.Close()
+
+		var apiErr *OutlineAPIError
+		assert.True(errors.As(err, &apiErr), name)
+		assert.Equal(tt.status, apiErr.StatusCode)
+		assert.Equal("/access-keys/1", apiErr.Endpoint)
+		assert.Equal(name, apiErr.Body)
+		if tt.target != nil {
+			assert.True(errors.Is(err, tt.target), name)
+		}
+	}
+
+	server, outlineInfo := newOutlineTestServer(func(w http.ResponseWriter, r *http.Request) {})
+	server.Close()
+
+	client, err := NewOutlineClient(outlineInfo, WithRetry(1, time.Millisecond, time.Millisecond))
+	assert.NoError(err)
+
+	_, err = client.GetAccessKeys(context.Background())
+	assert.True(errors.Is(err, ErrOutlineUnreachable))
+}
+
+func TestOutlineClientServer(t *testing.T) {
+	assert := assert.New(t)
+
+	var requests []string
+	server, outlineInfo := newOutlineTestServer(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, body))
+
+		if r.Method == http.MethodGet {
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"name":"vpn","serverId":"abc","metricsEnabled":true,"portForNewAccessKeys":443,"accessKeyDataLimit":{"bytes":1000}}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Close()
+
+	client, err := NewOutlineClient(outlineInfo)
+	assert.NoError(err)
+
+	ctx := context.Background()
+	outlineServer, err := client.GetServer(ctx)
+	assert.NoError(err)
+	assert.Equal("vpn", outlineServer.Name)
+	assert.Equal(443, outlineServer.PortForNewAccessKeys)
+	assert.Equal(1000, outlineServer.AccessKeyDataLimit.Bytes)
+
+	assert.NoError(client.RenameServer(ctx, "new"))
+	assert.NoError(client.SetHostnameForAccessKeys(ctx, "vpn.example.com"))
+	assert.NoError(client.SetPortForNewAccessKeys(ctx, 8443))
+	assert.NoError(client.AddDefaultDataLimit(ctx, 5000))
+	assert.NoError(client.DeleteDefaultDataLimit(ctx))
+	assert.NoError(client.SetMetricsEnabled(ctx, false))
+
+	assert.Equal([]string{
+		"GET /secret/server ",
+		`PUT /secret/name {"name":"new"}`,
+		`PUT /secret/server/hostname-for-access-keys {"hostname":"vpn.example.com"}`,
+		`PUT /secret/server/port-for-new-access-keys {"port":8443}`,
+		`PUT /secret/server/access-key-data-limit {"limit":{"bytes":5000}}`,
+		"DELETE /secret/server/access-key-data-limit ",
+		`PUT /secret/metrics/enabled {"metricsEnabled":false}`,
+	}, requests)
+}
+
+func TestOutlineClientCreateAccessKey(t *testing.T) {
+	assert := assert.New(t)
+
+	var requests []string
+	server, outlineInfo := newOutlineTestServer(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, body))
+
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":"7","name":"alice","port":443,"method":"chacha20-ietf-poly1305","dataLimit":{"bytes":20000000000}}`))
+	})
+	defer server.Close()
+
+	client, err := NewOutlineClient(outlineInfo)
+	assert.NoError(err)
+
+	ctx := context.Background()
+	_, err = client.CreateAccessKey(ctx, nil)
+	assert.NoError(err)
+
+	accessKey, err := client.CreateAccessKey(ctx, &AccessKey{
+		Name:      "alice",
+		Method:    "chacha20-ietf-poly1305",
+		Port:      443,
+		DataLimit: &DataLimit{Bytes: 20000000000},
+	})
+	assert.NoError(err)
+	assert.Equal("alice", accessKey.Name)
+	assert.Equal(20000000000, accessKey.DataLimit.Bytes)
+
+	_, err = client.CreateAccessKey(ctx, &AccessKey{ID: "7", Password: "secret"})
+	assert.NoError(err)
+
+	assert.Equal([]string{
+		"POST /secret/access-keys {}",
+		`POST /secret/access-keys {"limit":{"bytes":20000000000},"method":"chacha20-ietf-poly1305","name":"alice","port":443}`,
+		`PUT /secret/access-keys/7 {"password":"secret"}`,
+	}, requests)
+}
+
+func TestGetAccessKeysAllRegions(t *testing.T) {
+	assert := assert.New(t)
+
+	server, outlineInfo := newOutlineTestServer(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"accessKeys":[{"id":"0","name":"alice","accessUrl":"ss://example"}]}`))
+	})
+	defer server.Close()
+
+	client, err := NewOutlineClient(outlineInfo)
+	assert.NoError(err)
+
+	// nothing listens on the port of the closed server
+	closed, closedInfo := newOutlineTestServer(func(w http.ResponseWriter, r *http.Request) {})
+	closed.Close()
+
+	unreachable, err := NewOutlineClient(closedInfo, WithRetry(0, 0, 0))
+	assert.NoError(err)
+
+	results := GetAccessKeysAllRegions(context.Background(), map[string]*OutlineClient{
+		"us-east-1":      client,
+		"ap-northeast-2": unreachable,
+		"eu-west-1":      client,
+	})
+
+	assert.Len(results, 3)
+	assert.Equal("ap-northeast-2", results[0].Region)
+	assert.ErrorIs(results[0].Err, ErrOutlineUnreachable)
+	assert.Nil(results[0].AccessKeys)
+
+	for _, v := range results[1:] {
+		assert.NoError(v.Err)
+		assert.Equal("alice", v.AccessKeys.Keys[0].Name)
+	}
+	assert.Equal("eu-west-1", results[1].Region)
+	assert.Equal("us-east-1", results[2].Region)
+}
+
+func TestOutlineClientRetry(t *testing.T) {
+	assert := assert.New(t)
+
+	var attempts atomic.Int32
+	server, outlineInfo := newOutlineTestServer(func(w http.ResponseWriter, r *http.Request) {
+		// the server is booting for the first two attempts of every call
+		if attempts.Add(1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"accessKeys":[]}`))
+	})
+	defer server.Close()
+
+	var verbose strings.Builder
+	client, err := NewOutlineClient(outlineInfo, WithRetry(3, time.Millisecond, 5*time.Millisecond), WithVerbose(&verbose))
+	assert.NoError(err)
+
+	_, err = client.GetAccessKeys(context.Background())
+	assert.NoError(err)
+	assert.Equal(int32(3), attempts.Load())
+
+	lines := strings.Split(strings.TrimSpace(verbose.String()), "\n")
+	assert.Len(lines, 3)
+	assert.True(strings.HasPrefix(lines[0], "[attempt 1/4] GET /access-keys: 503 Service Unavailable"), lines[0])
+	assert.True(strings.HasSuffix(lines[0], ", retrying"), lines[0])
+	assert.True(strings.HasPrefix(lines[2], "[attempt 3/4] GET /access-keys: 200 OK"), lines[2])
+
+	// POST is not idempotent, a failure is returned at once
+	attempts.Store(0)
+	_, err = client.CreateAccessKey(context.Background(), nil)
+	assert.ErrorIs(err, ErrOutlineUnreachable)
+	assert.Equal(int32(1), attempts.Load())
+
+	// without retries the attempt is reported as well
+	verbose.Reset()
+	attempts.Store(0)
+	client, err = NewOutlineClient(outlineInfo, WithRetry(0, 0, 0), WithVerbose(&verbose))
+	assert.NoError(err)
+	_, err = client.GetAccessKeys(context.Background())
+	assert.Error(err)
+	assert.Equal("[attempt 1/1] GET /access-keys: 503 Service Unavailable", strings.Split(verbose.String(), " (")[0])
+
+	// and so is every failure to reach the server
+	closed, closedInfo := newOutlineTestServer(func(w http.ResponseWriter, r *http.Request) {})
+	closed.Close()
+
+	for retry, count := range map[int]int{0: 1, 2: 3} {
+		verbose.Reset()
+		client, err = NewOutlineClient(closedInfo, WithRetry(retry, time.Millisecond, time.Millisecond), WithVerbose(&verbose))
+		assert.NoError(err)
+		_, err = client.GetAccessKeys(context.Background())
+		assert.ErrorIs(err, ErrOutlineUnreachable)
+
+		lines := strings.Split(strings.TrimSpace(verbose.String()), "\n")
+		assert.Len(lines, count, verbose.String())
+		assert.Contains(lines[count-1], fmt.Sprintf("[attempt %d/%d] GET /access-keys: ", count, count))
+		assert.NotContains(lines[count-1], "retrying")
+	}
+}
+
+func TestOutlineClientTimeout(t *testing.T) {
+	assert := assert.New(t)
+
+	server, outlineInfo := newOutlineTestServer(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	})
+	defer server.Close()
+
+	client, err := NewOutlineClient(outlineInfo, WithTimeout(50*time.Millisecond))
+	assert.NoError(err)
+
+	start := time.Now()
+	_, err = client.GetAccessKeys(context.Background())
+	assert.ErrorIs(err, ErrOutlineUnreachable)
+	assert.Less(time.Since(start), 500*time.Millisecond)
+
+	// the deadline of the caller is honoured as well
+	client, err = NewOutlineClient(outlineInfo)
+	assert.NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start = time.Now()
+	_, err = client.GetAccessKeys(ctx)
+	assert.ErrorIs(err, ErrOutlineUnreachable)
+	assert.Less(time.Since(start), 500*time.Millisecond)
+}
@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+const (
+	PlanActionAdd     = "add"
+	PlanActionChange  = "change"
+	PlanActionReplace = "replace"
+	PlanActionDestroy = "destroy"
+
+	// PlanFileName is the saved plan in the workspace that apply runs.
+	PlanFileName = "outline.tfplan"
+)
+
+// planAttributes are the attributes worth reviewing before provisioning,
+// the other ones are ids and defaults of the providers.
+var planAttributes = []string{
+	"instance_type",
+	"ami",
+	"availability_zone",
+	"key_name",
+	"cidr_block",
+	"cidr_blocks",
+	"protocol",
+	"from_port",
+	"to_port",
+	"ingress",
+	"egress",
+}
+
+// PlanChange is a resource that a terraform plan adds, changes or destroys.
+type PlanChange struct {
+	Action     string
+	Address    string
+	Type       string
+	Attributes []string
+}
+
+// PlanChanges lists the resources of the plan that aren't left as they are,
+// with their key attributes (an update shows the old and the new value).
+func PlanChanges(plan *tfjson.Plan) []PlanChange {
+	changes := make([]PlanChange, 0)
+
+	for _, v := range plan.ResourceChanges {
+		if v.Change == nil || v.Mode == tfjson.DataResourceMode {
+			continue
+		}
+
+		var action string
+		switch actions := v.Change.Actions; {
+		case actions.Create():
+			action = PlanActionAdd
+		case actions.Update():
+			action = PlanActionChange
+		case actions.Replace():
+			action = PlanActionReplace
+		case actions.Delete():
+			action = PlanActionDestroy
+		default:
+			continue
+		}
+
+		before, _ := v.Change.Before.(map[string]interface{})
+		after, _ := v.Change.After.(map[string]interface{})
+
+		attributes := make([]string, 0)
+		for _, name := range planAttributes {
+			oldValue, hasOld := before[name]
+			newValue, hasNew := after[name]
+
+			switch {
+			case action == PlanActionDestroy && hasOld && oldValue != nil:
+				attributes = append(attributes, fmt.Sprintf("%s: %s", name, formatPlanValue(oldValue)))
+			case action == PlanActionDestroy || !hasNew || newValue == nil:
+			case action != PlanActionAdd && hasOld && oldValue != nil && formatPlanValue(oldValue) != formatPlanValue(newValue):
+				attributes = append(attributes, fmt.Sprintf("%s: %s -> %s", name, formatPlanValue(oldValue), formatPlanValue(newValue)))
+			default:
+				attributes = append(attributes, fmt.Sprintf("%s: %s", name, formatPlanValue(newValue)))
+			}
+		}
+
+		changes = append(changes, PlanChange{
+			Action:     action,
+			Address:    v.Address,
+			Type:       v.Type,
+			Attributes: attributes,
+		})
+	}
+
+	return changes
+}
+
+// formatPlanValue prints a value of the plan json, the rules of a security
+// group as "protocol from-to cidrs".
+func formatPlanValue(value interface{}) string {
+	switch v := value.(type) {
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, e := range v {
+			values = append(values, formatPlanValue(e))
+		}
+		sort.Strings(values)
+		return strings.Join(values, ", ")
+	case map[string]interface{}:
+		if _, ok := v["from_port"]; ok {
+			return fmt.Sprintf("%s %s-%s %s",
+				formatPlanValue(v["protocol"]),
+				formatPlanValue(v["from_port"]),
+				formatPlanValue(v["to_port"]),
+				formatPlanValue(v["cidr_blocks"]))
+		}
+		return fmt.Sprint(v)
+	case float64:
+		return fmt.Sprintf("%g", v)
+	case nil:
+		return ""
+	}
+	return fmt.Sprint(value)
+}
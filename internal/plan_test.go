@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanChanges(t *testing.T) {
+	assert := assert.New(t)
+
+	b := []byte(`{
+		"format_version": "1.2",
+		"resource_changes": [
+			{
+				"address": "module.outline-vpn.aws_instance.outline",
+				"mode": "managed",
+				"type": "aws_instance",
+				"change": {
+					"actions": ["create"],
+					"before": null,
+					"after": {"ami": "ami-1", "instance_type": "t2.micro", "availability_zone": "us-east-1a", "tags": {"Name": "govpn"}}
+				}
+			},
+			{
+				"address": "module.outline-vpn.aws_security_group.outline",
+				"mode": "managed",
+				"type": "aws_security_group",
+				"change": {
+					"actions": ["update"],
+					"before": {"ingress": [{"protocol": "tcp", "from_port": 22, "to_port": 22, "cidr_blocks": ["0.0.0.0/0"]}]},
+					"after": {"ingress": [{"protocol": "tcp", "from_port": 22, "to_port": 22, "cidr_blocks": ["10.0.0.0/8"]}]}
+				}
+			},
+			{
+				"address": "aws_key_pair.govpn_key",
+				"mode": "managed",
+				"type": "aws_key_pair",
+				"change": {
+					"actions": ["delete"],
+					"before": {"key_name": "govpn_us-east-1"},
+					"after": null
+				}
+			},
+			{
+				"address": "tls_private_key.tls",
+				"mode": "managed",
+				"type": "tls_private_key",
+				"change": {"actions": ["no-op"], "before": {}, "after": {}}
+			},
+			{
+				"address": "data.aws_ami.latest",
+				"mode": "data",
+				"type": "aws_ami",
+				"change": {"actions": ["read"], "before": null, "after": {}}
+			}
+		]
+	}`)
+
+	var plan tfjson.Plan
+	assert.NoError(json.Unmarshal(b, &plan))
+
+	assert.Equal([]PlanChange{
+		{
+			Action:     PlanActionAdd,
+			Address:    "module.outline-vpn.aws_instance.outline",
+			Type:       "aws_instance",
+			Attributes: []string{"instance_type: t2.micro", "ami: ami-1", "availability_zone: us-east-1a"},
+		},
+		{
+			Action:     PlanActionChange,
+			Address:    "module.outline-vpn.aws_security_group.outline",
+			Type:       "aws_security_group",
+			Attributes: []string{"ingress: tcp 22-22 0.0.0.0/0 -> tcp 22-22 10.0.0.0/8"},
+		},
+		{
+			Action:     PlanActionDestroy,
+			Address:    "aws_key_pair.govpn_key",
+			Type:       "aws_key_pair",
+			Attributes: []string{"key_name: govpn_us-east-1"},
+		},
+	}, PlanChanges(&plan))
+}
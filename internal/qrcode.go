@@ -0,0 +1,36 @@
+package internal
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/skip2/go-qrcode"
+)
+
+const qrCodePNGSize = 512
+
+// QRCodeString renders content as a QR code with unicode half blocks, sized for a dark terminal.
+func QRCodeString(content string) (string, error) {
+	qr, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return "", err
+	}
+	return qr.ToSmallString(false), nil
+}
+
+// WriteQRCodePNG writes the access url of accessKey as <name>-<id>-<region>.png
+// in dir, an unnamed key is written as accesskey-<id>-<region>.png. The id and
+// region keep the keys that share a name, in a region or across regions, apart.
+func WriteQRCodePNG(accessKey *AccessKey, region, dir string) (string, error) {
+	name := accessKey.FileName()
+	if !strings.HasSuffix(name, "-"+accessKey.ID) {
+		name = fmt.Sprintf("%s-%s", name, accessKey.ID)
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("%s-%s.png", name, region))
+	if err := qrcode.WriteFile(accessKey.AccessURL, qrcode.Medium, qrCodePNGSize, path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQRCodeString(t *testing.T) {
+	assert := assert.New(t)
+
+	qr, err := QRCodeString("ss://Y2hhY2hhMjAtaWV0Zi1wb2x5MTMwNTpzZWNyZXQ@127.0.0.1:443/?outline=1")
+	assert.NoError(err)
+	assert.True(strings.ContainsAny(qr, "█▀▄"))
+	assert.True(len(strings.Split(strings.TrimSpace(qr), "\n")) > 10)
+}
+
+func TestWriteQRCodePNG(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+
+	tests := map[string]struct {
+		accessKey *AccessKey
+		region    string
+		file      string
+	}{
+		"named":   {accessKey: &AccessKey{ID: "1", Name: "alice", AccessURL: "ss://alice"}, region: "us-east-1", file: "alice-1-us-east-1.png"},
+		"same":    {accessKey: &AccessKey{ID: "4", Name: "alice", AccessURL: "ss://alice4"}, region: "us-east-1", file: "alice-4-us-east-1.png"},
+		"region":  {accessKey: &AccessKey{ID: "1", Name: "alice", AccessURL: "ss://alice-eu"}, region: "eu-west-1", file: "alice-1-eu-west-1.png"},
+		"unsafe":  {accessKey: &AccessKey{ID: "2", Name: "bob/../kim", AccessURL: "ss://bob"}, region: "us-east-1", file: "bob_.._kim-2-us-east-1.png"},
+		"unnamed": {accessKey: &AccessKey{ID: "3", AccessURL: "ss://3"}, region: "us-east-1", file: "accesskey-3-us-east-1.png"},
+	}
+
+	for _, t := range tests {
+		path, err := WriteQRCodePNG(t.accessKey, t.region, dir)
+		assert.NoError(err)
+		assert.Equal(filepath.Join(dir, t.file), path)
+
+		b, err := os.ReadFile(path)
+		assert.NoError(err)
+		assert.Equal("\x89PNG", string(b[:4]))
+	}
+}
@@ -0,0 +1,106 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/go-resty/resty/v2"
+)
+
+const (
+	QuotaWarning  = "warning"
+	QuotaExceeded = "exceeded"
+)
+
+// QuotaAlert is an access key whose usage crossed the warning threshold of its data limit.
+type QuotaAlert struct {
+	Region           string  `json:"region"`
+	ID               string  `json:"id"`
+	Name             string  `json:"name"`
+	BytesTransferred int     `json:"bytesTransferred"`
+	DataLimit        int     `json:"dataLimit"`
+	PercentOfLimit   float64 `json:"percentOfLimit"`
+	Status           string  `json:"status"`
+}
+
+// ParsePercent converts "80%" or "80" into 80.
+func ParsePercent(value string) (float64, error) {
+	percent, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(value), "%"), 64)
+	if err != nil || percent <= 0 {
+		return 0, fmt.Errorf("invalid percent %q", value)
+	}
+	return percent, nil
+}
+
+// CheckQuotas returns the keys that used at least warn percent of their data
+// limit. A key without its own limit is checked against the server default,
+// defaultLimit nil means there is none. A limit of 0 bytes has always been
+// exceeded, the key is blocked.
+func CheckQuotas(region string, usages []KeyUsage, defaultLimit *int, warn float64) []QuotaAlert {
+	alerts := make([]QuotaAlert, 0)
+
+	for _, v := range usages {
+		limitBytes := v.DataLimit
+		if limitBytes == nil {
+			limitBytes = defaultLimit
+		}
+		if limitBytes == nil {
+			continue
+		}
+
+		limit, percent := *limitBytes, 100.0
+		if limit > 0 {
+			percent = float64(v.BytesTransferred) / float64(limit) * 100
+		}
+		if percent < warn {
+			continue
+		}
+
+		status := QuotaWarning
+		if v.BytesTransferred >= limit {
+			status = QuotaExceeded
+		}
+
+		alerts = append(alerts, QuotaAlert{
+			Region:           region,
+			ID:               v.ID,
+			Name:             v.Name,
+			BytesTransferred: v.BytesTransferred,
+			DataLimit:        limit,
+			PercentOfLimit:   percent,
+			Status:           status,
+		})
+	}
+
+	return alerts
+}
+
+// PostQuotaAlerts sends the alerts as json to the webhook. text summarizes
+// them for chat webhooks that only display a message.
+func PostQuotaAlerts(ctx context.Context, webhookURL string, alerts []QuotaAlert) error {
+	lines := make([]string, 0, len(alerts)+1)
+	lines = append(lines, fmt.Sprintf("outline-vpn: %d access keys crossed their data limit threshold", len(alerts)))
+	for _, v := range alerts {
+		lines = append(lines, fmt.Sprintf("[%s] %s (ID: %s, %s) %s of %s (%.1f%%)",
+			v.Status, v.Name, v.ID, v.Region, FormatDataSize(v.BytesTransferred), FormatDataSize(v.DataLimit), v.PercentOfLimit))
+	}
+
+	resp, err := resty.New().R().
+		SetContext(ctx).
+		SetHeader("Content-Type", "application/json").
+		SetBody(map[string]interface{}{
+			"text":   strings.Join(lines, "\n"),
+			"alerts": alerts,
+		}).
+		Post(webhookURL)
+	if err != nil {
+		return err
+	}
+
+	if resp.IsError() {
+		return fmt.Errorf("webhook %s responded %s", webhookURL, resp.Status())
+	}
+	return nil
+}
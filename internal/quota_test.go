@@ -0,0 +1,104 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePercent(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]struct {
+		percent float64
+		isErr   bool
+	}{
+		"80%":   {percent: 80},
+		"80":    {percent: 80},
+		"95.5%": {percent: 95.5},
+		"0%":    {isErr: true},
+		"%":     {isErr: true},
+		"high":  {isErr: true},
+	}
+
+	for value, test := range tests {
+		percent, err := ParsePercent(value)
+		assert.Equal(test.isErr, err != nil, value)
+		assert.Equal(test.percent, percent, value)
+	}
+}
+
+func TestCheckQuotas(t *testing.T) {
+	assert := assert.New(t)
+
+	bytes := func(v int) *int { return &v }
+
+	usages := []KeyUsage{
+		{ID: "0", Name: "alice", BytesTransferred: 85, DataLimit: bytes(100)},
+		{ID: "1", Name: "bob", BytesTransferred: 120, DataLimit: bytes(100)},
+		{ID: "2", Name: "carol", BytesTransferred: 50, DataLimit: bytes(100)},
+		{ID: "3", Name: "dave", BytesTransferred: 900},
+		{ID: "4", Name: "erin", BytesTransferred: 10},
+		// an explicit limit of 0 bytes blocks the key, it's not the default
+		{ID: "5", Name: "frank", DataLimit: bytes(0)},
+	}
+
+	tests := map[string]struct {
+		defaultLimit *int
+		ids          []string
+		statuses     []string
+	}{
+		"no-default": {
+			ids:      []string{"0", "1", "5"},
+			statuses: []string{QuotaWarning, QuotaExceeded, QuotaExceeded},
+		},
+		"default": {
+			defaultLimit: bytes(1000),
+			ids:          []string{"0", "1", "3", "5"},
+			statuses:     []string{QuotaWarning, QuotaExceeded, QuotaWarning, QuotaExceeded},
+		},
+	}
+
+	for name, test := range tests {
+		alerts := CheckQuotas("us-east-1", usages, test.defaultLimit, 80)
+
+		ids, statuses := make([]string, 0), make([]string, 0)
+		for _, v := range alerts {
+			assert.Equal("us-east-1", v.Region, name)
+			ids = append(ids, v.ID)
+			statuses = append(statuses, v.Status)
+		}
+		assert.Equal(test.ids, ids, name)
+		assert.Equal(test.statuses, statuses, name)
+	}
+}
+
+func TestPostQuotaAlerts(t *testing.T) {
+	assert := assert.New(t)
+
+	var received struct {
+		Text   string       `json:"text"`
+		Alerts []QuotaAlert `json:"alerts"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(http.MethodPost, r.Method)
+		assert.NoError(json.NewDecoder(r.Body).Decode(&received))
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	limit := 100
+	alerts := CheckQuotas("us-east-1", []KeyUsage{{ID: "1", Name: "bob", BytesTransferred: 120, DataLimit: &limit}}, nil, 80)
+
+	assert.NoError(PostQuotaAlerts(context.Background(), server.URL+"/hook", alerts))
+	assert.Equal(alerts, received.Alerts)
+	assert.Contains(received.Text, "[exceeded] bob (ID: 1, us-east-1)")
+
+	assert.Error(PostQuotaAlerts(context.Background(), server.URL+"/fail", alerts))
+}
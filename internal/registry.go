@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// KeyMetadata is what outline-vpn keeps about an access key that the
+// outline server has no field for.
+type KeyMetadata struct {
+	Owner     string     `json:"owner,omitempty"`
+	Email     string     `json:"email,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	Notes     string     `json:"notes,omitempty"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	// DynamicKeyToken is the name the dynamic key of the access key is published under.
+	DynamicKeyToken string `json:"dynamicKeyToken,omitempty"`
+}
+
+func (m *KeyMetadata) empty() bool {
+	return m.Owner == "" && m.Email == "" && len(m.Tags) == 0 && m.Notes == "" && m.ExpiresAt == nil && m.DynamicKeyToken == ""
+}
+
+func (m *KeyMetadata) HasTag(tag string) bool {
+	for _, v := range m.Tags {
+		if strings.EqualFold(v, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// GetOwner is the owner with the email for a table, "-" when there is neither.
+func (m *KeyMetadata) GetOwner() string {
+	switch {
+	case m.Owner != "" && m.Email != "":
+		return fmt.Sprintf("%s <%s>", m.Owner, m.Email)
+	case m.Owner != "":
+		return m.Owner
+	case m.Email != "":
+		return m.Email
+	}
+	return "-"
+}
+
+func (m *KeyMetadata) GetTags() string {
+	if len(m.Tags) == 0 {
+		return "-"
+	}
+	return strings.Join(m.Tags, ",")
+}
+
+// KeyRegistry is the metadata of the access keys of the outline server in
+// a workspace by access key id. ServerID tells a registry left behind by a
+// destroyed server apart, its ids are handed out again by the new one.
+type KeyRegistry struct {
+	ServerID string                  `json:"serverId"`
+	Keys     map[string]*KeyMetadata `json:"keys"`
+}
+
+// KeyRegistryPath is accesskeys.json next to outline.json of the workspace.
+func KeyRegistryPath(region string) string {
+	return ReturnTerraformPath(region) + "/accesskeys.json"
+}
+
+// LoadKeyRegistry reads the registry at path for the outline server with
+// serverID. A missing file or a registry of another server is empty.
+func LoadKeyRegistry(path, serverID string) (*KeyRegistry, error) {
+	registry := &KeyRegistry{ServerID: serverID, Keys: make(map[string]*KeyMetadata)}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return registry, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var saved KeyRegistry
+	if err = json.Unmarshal(b, &saved); err != nil {
+		return nil, err
+	}
+
+	if saved.ServerID == serverID && saved.Keys != nil {
+		registry.Keys = saved.Keys
+	}
+	return registry, nil
+}
+
+// Save writes the registry to path, leaving out the keys without metadata.
+func (r *KeyRegistry) Save(path string) error {
+	for id, v := range r.Keys {
+		if v.empty() {
+			delete(r.Keys, id)
+		}
+	}
+
+	b, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+// Get returns the metadata of the access key, an empty one when there is none yet.
+func (r *KeyRegistry) Get(id string) *KeyMetadata {
+	if metadata, ok := r.Keys[id]; ok {
+		return metadata
+	}
+
+	metadata := &KeyMetadata{}
+	r.Keys[id] = metadata
+	return metadata
+}
+
+// DynamicKeyToken is the token the dynamic key of the access key is
+// published under, a new one the first time so its url stays the same.
+func (r *KeyRegistry) DynamicKeyToken(id string) (string, error) {
+	metadata := r.Get(id)
+	if metadata.DynamicKeyToken == "" {
+		token, err := NewDynamicKeyToken()
+		if err != nil {
+			return "", err
+		}
+		metadata.DynamicKeyToken = token
+	}
+	return metadata.DynamicKeyToken, nil
+}
+
+func (r *KeyRegistry) Delete(id string) {
+	delete(r.Keys, id)
+}
+
+// Expired returns the ids of the access keys whose expiry has passed.
+func (r *KeyRegistry) Expired(now time.Time) []string {
+	ids := make([]string, 0)
+	for id, v := range r.Keys {
+		if v.ExpiresAt != nil && !v.ExpiresAt.After(now) {
+			ids = append(ids, id)
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool {
+		return lessID(ids[i], ids[j])
+	})
+	return ids
+}
+
+// Lookup returns the metadata of the access key without adding it, nil when there is none.
+func (r *KeyRegistry) Lookup(id string) *KeyMetadata {
+	return r.Keys[id]
+}
+
+// Filter returns the access keys owned by owner and tagged with tag, an empty value matches every key.
+func (r *KeyRegistry) Filter(accessKeys *AccessKeys, owner, tag string) *AccessKeys {
+	result := &AccessKeys{Keys: make([]AccessKey, 0, len(accessKeys.Keys))}
+	for _, v := range accessKeys.Keys {
+		metadata := r.Lookup(v.ID)
+		if metadata == nil {
+			metadata = &KeyMetadata{}
+		}
+
+		if (owner == "" || strings.EqualFold(metadata.Owner, owner)) && (tag == "" || metadata.HasTag(tag)) {
+			result.Keys = append(result.Keys, v)
+		}
+	}
+	return result
+}
+
+// Reconcile drops the metadata of the access keys that no longer exist on
+// the server and returns their ids.
+func (r *KeyRegistry) Reconcile(accessKeys *AccessKeys) []string {
+	exists := make(map[string]bool, len(accessKeys.Keys))
+	for _, v := range accessKeys.Keys {
+		exists[v.ID] = true
+	}
+
+	removed := make([]string, 0)
+	for id := range r.Keys {
+		if !exists[id] {
+			removed = append(removed, id)
+			delete(r.Keys, id)
+		}
+	}
+
+	sort.Slice(removed, func(i, j int) bool {
+		return lessID(removed[i], removed[j])
+	})
+	return removed
+}
@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyRegistry(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "accesskeys.json")
+
+	registry, err := LoadKeyRegistry(path, "server-a")
+	assert.NoError(err)
+	assert.Empty(registry.Keys)
+
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	past, future := now.Add(-time.Hour), now.Add(time.Hour)
+
+	registry.Get("10").ExpiresAt = &past
+	registry.Get("2").ExpiresAt = &now
+	registry.Get("3").ExpiresAt = &future
+	registry.Get("4")
+	assert.NoError(registry.Save(path))
+
+	registry, err = LoadKeyRegistry(path, "server-a")
+	assert.NoError(err)
+	assert.Len(registry.Keys, 3)
+	assert.Equal([]string{"2", "10"}, registry.Expired(now))
+
+	registry.Delete("2")
+	assert.Equal([]string{"10"}, registry.Expired(now))
+
+	// the ids of a recreated server start over
+	registry, err = LoadKeyRegistry(path, "server-b")
+	assert.NoError(err)
+	assert.Empty(registry.Keys)
+	assert.Equal("server-b", registry.ServerID)
+}
+
+func TestKeyRegistryDynamicKeyToken(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "accesskeys.json")
+
+	registry, err := LoadKeyRegistry(path, "server-a")
+	assert.NoError(err)
+
+	token, err := registry.DynamicKeyToken("1")
+	assert.NoError(err)
+	other, err := registry.DynamicKeyToken("2")
+	assert.NoError(err)
+	assert.NotEqual(token, other)
+	assert.NoError(registry.Save(path))
+
+	// the token is kept, so the published url stays the same
+	registry, err = LoadKeyRegistry(path, "server-a")
+	assert.NoError(err)
+	again, err := registry.DynamicKeyToken("1")
+	assert.NoError(err)
+	assert.Equal(token, again)
+}
+
+func TestKeyRegistryFilter(t *testing.T) {
+	assert := assert.New(t)
+
+	registry := &KeyRegistry{Keys: map[string]*KeyMetadata{
+		"0": {Owner: "alice", Tags: []string{"sales"}},
+		"1": {Owner: "Bob", Email: "bob@example.com", Tags: []string{"sales", "eu"}},
+		"2": {Owner: "bob"},
+	}}
+	accessKeys := &AccessKeys{Keys: []AccessKey{{ID: "0"}, {ID: "1"}, {ID: "2"}, {ID: "3"}}}
+
+	tests := map[string]struct {
+		owner string
+		tag   string
+		ids   []string
+	}{
+		"all":       {ids: []string{"0", "1", "2", "3"}},
+		"owner":     {owner: "bob", ids: []string{"1", "2"}},
+		"tag":       {tag: "SALES", ids: []string{"0", "1"}},
+		"owner-tag": {owner: "bob", tag: "sales", ids: []string{"1"}},
+		"none":      {owner: "carol", ids: []string{}},
+	}
+
+	for name, test := range tests {
+		ids := make([]string, 0)
+		for _, v := range registry.Filter(accessKeys, test.owner, test.tag).Keys {
+			ids = append(ids, v.ID)
+		}
+		assert.Equal(test.ids, ids, name)
+	}
+
+	assert.Equal("Bob <bob@example.com>", registry.Lookup("1").GetOwner())
+	assert.Equal("sales,eu", registry.Lookup("1").GetTags())
+	assert.Nil(registry.Lookup("3"))
+}
+
+func TestKeyRegistryReconcile(t *testing.T) {
+	assert := assert.New(t)
+
+	registry := &KeyRegistry{Keys: map[string]*KeyMetadata{
+		"0":  {Owner: "alice"},
+		"2":  {Owner: "bob"},
+		"10": {Notes: "deleted on the server"},
+	}}
+
+	removed := registry.Reconcile(&AccessKeys{Keys: []AccessKey{{ID: "0"}, {ID: "1"}}})
+	assert.Equal([]string{"2", "10"}, removed)
+	assert.Len(registry.Keys, 1)
+	assert.Equal("alice", registry.Lookup("0").Owner)
+}
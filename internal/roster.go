@@ -0,0 +1,185 @@
+package internal
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	RosterCreated  = "created"
+	RosterExisting = "existing"
+	RosterFailed   = "failed"
+)
+
+// RosterEntry is one access key in a roster file. Limit is in human units (e.g. 20GB) and optional.
+type RosterEntry struct {
+	Name   string `yaml:"name"`
+	Region string `yaml:"region"`
+	Limit  string `yaml:"limit"`
+}
+
+// RosterResult is the outcome of a roster entry, written to the result file.
+type RosterResult struct {
+	Name      string `json:"name"`
+	Region    string `json:"region"`
+	ID        string `json:"id,omitempty"`
+	AccessURL string `json:"accessUrl,omitempty"`
+	Status    string `json:"status"`
+	Error     string `json:"error,omitempty"`
+}
+
+// ReadRoster reads a roster from a .csv file with a name,region,limit header or from a .yaml/.yml list.
+func ReadRoster(path string) ([]RosterEntry, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []RosterEntry
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		entries, err = readRosterCSV(f)
+	case ".yaml", ".yml":
+		err = yaml.NewDecoder(f).Decode(&entries)
+	default:
+		return nil, fmt.Errorf("unsupported roster %s (csv, yaml)", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read roster %s: %w", path, err)
+	}
+
+	for i, entry := range entries {
+		if entry.Name == "" || entry.Region == "" {
+			return nil, fmt.Errorf("roster %s entry %d: name and region are required", path, i+1)
+		}
+		if entry.Limit != "" {
+			if _, err := ParseDataSize(entry.Limit); err != nil {
+				return nil, fmt.Errorf("roster %s entry %d: %w", path, i+1, err)
+			}
+		}
+	}
+
+	return entries, nil
+}
+
+func readRosterCSV(r io.Reader) ([]RosterEntry, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, nil
+	}
+
+	columns := make(map[string]int)
+	for i, column := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	for _, column := range []string{"name", "region"} {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("missing %s column", column)
+		}
+	}
+
+	field := func(record []string, column string) string {
+		i, ok := columns[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return strings.TrimSpace(record[i])
+	}
+
+	entries := make([]RosterEntry, 0, len(records)-1)
+	for _, record := range records[1:] {
+		entries = append(entries, RosterEntry{
+			Name:   field(record, "name"),
+			Region: field(record, "region"),
+			Limit:  field(record, "limit"),
+		})
+	}
+	return entries, nil
+}
+
+// ApplyRoster creates the roster entries missing on the outline servers. An
+// entry whose name already exists in its region is skipped, so a roster can be
+// applied again. clients holds the outline client of every region in the roster.
+func ApplyRoster(ctx context.Context, clients map[string]*OutlineClient, entries []RosterEntry) []RosterResult {
+	var (
+		results    = make([]RosterResult, 0, len(entries))
+		accessKeys = make(map[string]*AccessKeys)
+	)
+
+	for _, entry := range entries {
+		result := RosterResult{Name: entry.Name, Region: entry.Region, Status: RosterFailed}
+
+		accessKey, status, err := applyRosterEntry(ctx, clients, accessKeys, entry)
+		if err != nil {
+			result.Error = err.Error()
+		} else {
+			result.ID = accessKey.ID
+			result.AccessURL = accessKey.AccessURL
+			result.Status = status
+		}
+		results = append(results, result)
+	}
+
+	return results
+}
+
+func applyRosterEntry(ctx context.Context, clients map[string]*OutlineClient, accessKeys map[string]*AccessKeys, entry RosterEntry) (*AccessKey, string, error) {
+	client, ok := clients[entry.Region]
+	if !ok {
+		return nil, "", fmt.Errorf("there is no outline server in %s", entry.Region)
+	}
+
+	if _, ok := accessKeys[entry.Region]; !ok {
+		keys, err := client.GetAccessKeys(ctx)
+		if err != nil {
+			return nil, "", err
+		}
+		accessKeys[entry.Region] = keys
+	}
+
+	if existing := accessKeys[entry.Region].Filter("", entry.Name); len(existing.Keys) > 0 {
+		return &existing.Keys[0], RosterExisting, nil
+	}
+
+	template := &AccessKey{Name: entry.Name}
+	if entry.Limit != "" {
+		bytes, err := ParseDataSize(entry.Limit)
+		if err != nil {
+			return nil, "", err
+		}
+		template.DataLimit = &DataLimit{Bytes: bytes}
+	}
+
+	accessKey, err := client.CreateAccessKey(ctx, template)
+	if err != nil {
+		return nil, "", err
+	}
+	accessKeys[entry.Region].Keys = append(accessKeys[entry.Region].Keys, *accessKey)
+
+	return accessKey, RosterCreated, nil
+}
+
+// WriteRosterResult saves the results as json, mapping every name to its access url.
+func WriteRosterResult(results []RosterResult, path string) error {
+	b, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
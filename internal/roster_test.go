@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadRoster(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"roster.csv":   "name, region, limit\nalice,us-east-1,20GB\nbob,ap-northeast-2,\n",
+		"roster.yaml":  "- name: alice\n  region: us-east-1\n  limit: 20GB\n- name: bob\n  region: ap-northeast-2\n",
+		"noregion.csv": "name,limit\nalice,20GB\n",
+		"badlimit.yml": "- name: alice\n  region: us-east-1\n  limit: lots\n",
+		"roster.txt":   "alice",
+	}
+	for name, content := range files {
+		assert.NoError(os.WriteFile(filepath.Join(dir, name), []byte(content), 0644))
+	}
+
+	tests := map[string]struct {
+		file  string
+		isErr bool
+	}{
+		"csv":       {file: "roster.csv"},
+		"yaml":      {file: "roster.yaml"},
+		"no-region": {file: "noregion.csv", isErr: true},
+		"bad-limit": {file: "badlimit.yml", isErr: true},
+		"extension": {file: "roster.txt", isErr: true},
+		"missing":   {file: "missing.csv", isErr: true},
+	}
+
+	for name, t := range tests {
+		entries, err := ReadRoster(filepath.Join(dir, t.file))
+		assert.Equal(t.isErr, err != nil, name)
+		if err != nil {
+			continue
+		}
+
+		assert.Equal([]RosterEntry{
+			{Name: "alice", Region: "us-east-1", Limit: "20GB"},
+			{Name: "bob", Region: "ap-northeast-2"},
+		}, entries, name)
+	}
+}
+
+func TestApplyRoster(t *testing.T) {
+	assert := assert.New(t)
+
+	var created []map[string]interface{}
+	server, outlineInfo := newOutlineTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"accessKeys":[{"id":"0","name":"alice","accessUrl":"ss://alice"}]}`))
+		case http.MethodPost:
+			var body map[string]interface{}
+			json.NewDecoder(r.Body).Decode(&body)
+			created = append(created, body)
+
+			w.WriteHeader(http.StatusCreated)
+			json.NewEncoder(w).Encode(map[string]string{"id": "1", "name": body["name"].(string), "accessUrl": "ss://new"})
+		}
+	})
+	defer server.Close()
+
+	client, err := NewOutlineClient(outlineInfo)
+	assert.NoError(err)
+
+	results := ApplyRoster(context.Background(), map[string]*OutlineClient{"us-east-1": client}, []RosterEntry{
+		{Name: "alice", Region: "us-east-1"},
+		{Name: "bob", Region: "us-east-1", Limit: "1KB"},
+		{Name: "bob", Region: "us-east-1"},
+		{Name: "carol", Region: "eu-west-1"},
+	})
+
+	assert.Equal([]RosterResult{
+		{Name: "alice", Region: "us-east-1", ID: "0", AccessURL: "ss://alice", Status: RosterExisting},
+		{Name: "bob", Region: "us-east-1", ID: "1", AccessURL: "ss://new", Status: RosterCreated},
+		{Name: "bob", Region: "us-east-1", ID: "1", AccessURL: "ss://new", Status: RosterExisting},
+		{Name: "carol", Region: "eu-west-1", Status: RosterFailed, Error: "there is no outline server in eu-west-1"},
+	}, results)
+
+	assert.Equal(1, len(created))
+	assert.Equal(map[string]interface{}{"name": "bob", "limit": map[string]interface{}{"bytes": float64(1000)}}, created[0])
+}
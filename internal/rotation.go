@@ -0,0 +1,108 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"strings"
+	"time"
+)
+
+const rotatedSuffix = " [rotated]"
+
+// PendingRotation is an access key that was replaced and is deleted once DeleteAfter has passed.
+// ServerID is the outline server of the key, a recreated server hands out the same ids again.
+type PendingRotation struct {
+	Region      string    `json:"region"`
+	ServerID    string    `json:"serverId"`
+	ID          string    `json:"id"`
+	Name        string    `json:"name"`
+	ReplacedBy  string    `json:"replacedBy"`
+	DeleteAfter time.Time `json:"deleteAfter"`
+}
+
+// RotationState is kept in ~/.outline-vpn so the old keys can be deleted on a later run.
+type RotationState struct {
+	Pending []PendingRotation `json:"pending"`
+}
+
+func LoadRotationState(path string) (*RotationState, error) {
+	state := &RotationState{}
+
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(b, state); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+func (s *RotationState) Save(path string) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0600)
+}
+
+func (s *RotationState) Add(pending PendingRotation) {
+	s.Pending = append(s.Pending, pending)
+}
+
+// Due returns the pending rotations whose grace period has passed.
+func (s *RotationState) Due(now time.Time) []PendingRotation {
+	due := make([]PendingRotation, 0)
+	for _, v := range s.Pending {
+		if !now.Before(v.DeleteAfter) {
+			due = append(due, v)
+		}
+	}
+	return due
+}
+
+func (s *RotationState) Remove(region, id string) {
+	pending := s.Pending[:0]
+	for _, v := range s.Pending {
+		if v.Region != region || v.ID != id {
+			pending = append(pending, v)
+		}
+	}
+	s.Pending = pending
+}
+
+// RotateAccessKey creates a replacement with the same name, method, port and
+// data limit as accessKey, and marks the old key as rotated by its name.
+// The old key keeps working until it's deleted.
+func RotateAccessKey(ctx context.Context, client *OutlineClient, accessKey *AccessKey) (*AccessKey, error) {
+	replacement, err := client.CreateAccessKey(ctx, &AccessKey{
+		Name:      accessKey.Name,
+		Method:    accessKey.Method,
+		Port:      accessKey.Port,
+		DataLimit: accessKey.DataLimit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err = applyNameAndLimit(ctx, client, replacement, accessKey); err != nil {
+		return replacement, err
+	}
+
+	if err = client.RenameAccessKey(ctx, accessKey.ID, accessKey.Name+rotatedSuffix); err != nil {
+		return replacement, err
+	}
+
+	return replacement, nil
+}
+
+// IsRotated reports whether the access key was already replaced by RotateAccessKey.
+func IsRotated(accessKey *AccessKey) bool {
+	return strings.HasSuffix(accessKey.Name, rotatedSuffix)
+}
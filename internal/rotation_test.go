@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotationState(t *testing.T) {
+	assert := assert.New(t)
+
+	path := filepath.Join(t.TempDir(), "rotation.json")
+
+	state, err := LoadRotationState(path)
+	assert.NoError(err)
+	assert.Equal(0, len(state.Pending))
+
+	now := time.Now()
+	state.Add(PendingRotation{Region: "us-east-1", ServerID: "a", ID: "1", DeleteAfter: now.Add(-time.Hour)})
+	state.Add(PendingRotation{Region: "us-east-1", ID: "2", DeleteAfter: now.Add(time.Hour)})
+	state.Add(PendingRotation{Region: "eu-west-1", ID: "1", DeleteAfter: now})
+	assert.NoError(state.Save(path))
+
+	state, err = LoadRotationState(path)
+	assert.NoError(err)
+	assert.Equal(3, len(state.Pending))
+
+	due := state.Due(now)
+	assert.Equal(2, len(due))
+	assert.Equal("a", due[0].ServerID)
+
+	state.Remove("us-east-1", "1")
+	assert.Equal(2, len(state.Pending))
+	assert.Equal(1, len(state.Due(now)))
+	assert.Equal("eu-west-1", state.Due(now)[0].Region)
+}
+
+func TestRotateAccessKey(t *testing.T) {
+	assert := assert.New(t)
+
+	var requests []string
+	server, outlineInfo := newOutlineTestServer(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, fmt.Sprintf("%s %s %s", r.Method, r.URL.Path, body))
+
+		if r.Method == http.MethodPost {
+			// an older server ignoring the name and limit
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"id":"8","accessUrl":"ss://new"}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Close()
+
+	client, err := NewOutlineClient(outlineInfo)
+	assert.NoError(err)
+
+	accessKey := &AccessKey{ID: "3", Name: "alice", Method: "aes-256-gcm", Port: 443, DataLimit: &DataLimit{Bytes: 100}}
+	assert.False(IsRotated(accessKey))
+
+	replacement, err := RotateAccessKey(context.Background(), client, accessKey)
+	assert.NoError(err)
+	assert.Equal("8", replacement.ID)
+	assert.Equal("alice", replacement.Name)
+	assert.Equal(100, replacement.DataLimit.Bytes)
+
+	assert.Equal([]string{
+		`POST /secret/access-keys {"limit":{"bytes":100},"method":"aes-256-gcm","name":"alice","port":443}`,
+		`PUT /secret/access-keys/8/name {"name":"alice"}`,
+		`PUT /secret/access-keys/8/data-limit {"limit":{"bytes":100}}`,
+		`PUT /secret/access-keys/3/name {"name":"alice [rotated]"}`,
+	}, requests)
+
+	assert.True(IsRotated(&AccessKey{Name: "alice [rotated]"}))
+}
@@ -2,8 +2,10 @@ package internal
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 
 	"github.com/hashicorp/go-version"
 	"github.com/hashicorp/hc-install/product"
@@ -110,32 +112,62 @@ func CreateWorkspace(ctx context.Context, execPath, _defaultTerraformPath, regio
 	return tf.WorkspaceNew(ctx, regionName)
 }
 
-func CreateTf(workSpacePath string, region, ami, instanceType, az string) error {
+func CreateTf(workSpacePath string, region, ami, instanceType, az string, backend *Backend, module *ModuleSource) error {
 
-	err := CreateMainDotTf(workSpacePath, region, ami, instanceType, az)
+	err := CreateModuleDir(workSpacePath, module)
 	if err != nil {
 		return err
 	}
 
-	err = CreateProviderDotTf(workSpacePath, region)
+	err = CreateBackendDotTf(workSpacePath, region, backend)
 	if err != nil {
 		return err
 	}
 
-	err = CreateOutputDotTf(workSpacePath)
+	return createRootTf(workSpacePath, region, ami, instanceType, az, module)
+}
+
+// RenderTf writes the files of CreateTf into dir and removes nothing there:
+// the files are overwritten and a backend.tf is left as it is with the local
+// backend, which has none.
+func RenderTf(dir string, region, ami, instanceType, az string, backend *Backend, module *ModuleSource) error {
+	if module.Type == ModuleEmbedded {
+		if err := copyModule(moduleFS, embeddedModuleRoot, filepath.Join(dir, embeddedModulePath)); err != nil {
+			return err
+		}
+	}
+
+	if !backend.IsLocal() {
+		if err := CreateBackendDotTf(dir, region, backend); err != nil {
+			return err
+		}
+	}
+
+	return createRootTf(dir, region, ami, instanceType, az, module)
+}
+
+// createRootTf writes main.tf, provider.tf, output.tf and key.tf.
+func createRootTf(workSpacePath string, region, ami, instanceType, az string, module *ModuleSource) error {
+
+	err := CreateMainDotTf(workSpacePath, region, ami, instanceType, az, module)
 	if err != nil {
 		return err
 	}
 
-	err = CreateKeyDotTf(workSpacePath)
+	err = CreateProviderDotTf(workSpacePath, region)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	err = CreateOutputDotTf(workSpacePath)
+	if err != nil {
+		return err
+	}
+
+	return CreateKeyDotTf(workSpacePath)
 }
 
-func CreateMainDotTf(workSpacePath string, region, ami, instanceType, az string) error {
+func CreateMainDotTf(workSpacePath string, region, ami, instanceType, az string, module *ModuleSource) error {
 	var fileName = fmt.Sprintf(workSpacePath + "/main.tf")
 
 	f := hclwrite.NewEmptyFile()
@@ -143,8 +175,11 @@ func CreateMainDotTf(workSpacePath string, region, ami, instanceType, az string)
 
 	moduleBlock := rootBody.AppendNewBlock("module", []string{moduleName})
 	moduleBody := moduleBlock.Body()
-	moduleBody.SetAttributeValue("source", cty.StringVal(rootModule))
-	moduleBody.SetAttributeValue("version", cty.StringVal(moduleVersion))
+	source, version := module.Attributes()
+	moduleBody.SetAttributeValue("source", cty.StringVal(source))
+	if version != "" {
+		moduleBody.SetAttributeValue("version", cty.StringVal(version))
+	}
 	moduleBody.SetAttributeValue("aws_region", cty.StringVal(region))
 	moduleBody.SetAttributeValue("ec2_ami", cty.StringVal(ami))
 	moduleBody.SetAttributeValue("instance_type", cty.StringVal(instanceType))
@@ -181,6 +216,50 @@ func CreateProviderDotTf(workSpacePath string, region string) error {
 	return os.WriteFile(fileName, f.Bytes(), 0644)
 }
 
+// CreateBackendDotTf configures where terraform keeps the state of the
+// workspace, the local backend needs no backend.tf.
+func CreateBackendDotTf(workSpacePath string, region string, backend *Backend) error {
+	var fileName = fmt.Sprintf(workSpacePath + "/backend.tf")
+
+	if backend.IsLocal() {
+		if err := os.Remove(fileName); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	f := hclwrite.NewEmptyFile()
+	rootBody := f.Body()
+
+	terraformBlock := rootBody.AppendNewBlock("terraform", nil)
+	backendBlock := terraformBlock.Body().AppendNewBlock("backend", []string{backend.Type})
+	backendBody := backendBlock.Body()
+
+	switch backend.Type {
+	case BackendS3:
+		backendBody.SetAttributeValue("bucket", cty.StringVal(backend.Bucket))
+		backendBody.SetAttributeValue("key", cty.StringVal(backend.Key(region)))
+		backendBody.SetAttributeValue("region", cty.StringVal(backend.Region))
+		backendBody.SetAttributeValue("encrypt", cty.BoolVal(true))
+		if backend.LockTable != "" {
+			backendBody.SetAttributeValue("dynamodb_table", cty.StringVal(backend.LockTable))
+		}
+	case BackendHTTP:
+		address, lockAddress, unlockAddress := backend.Addresses(region)
+		backendBody.SetAttributeValue("address", cty.StringVal(address))
+		if lockAddress != "" {
+			backendBody.SetAttributeValue("lock_address", cty.StringVal(lockAddress))
+		}
+		if unlockAddress != "" {
+			backendBody.SetAttributeValue("unlock_address", cty.StringVal(unlockAddress))
+		}
+	default:
+		return backend.Validate()
+	}
+
+	return os.WriteFile(fileName, f.Bytes(), 0644)
+}
+
 func CreateOutputDotTf(workSpacePath string) error {
 	var fileName = fmt.Sprintf(workSpacePath + "/output.tf")
 
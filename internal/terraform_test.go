@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+func TestCreateTf(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := map[string]struct {
+		backend *Backend
+		module  *ModuleSource
+		files   []string
+		// the directories next to the files
+		dirs []string
+	}{
+		"embedded": {
+			backend: &Backend{Type: BackendLocal},
+			module:  &ModuleSource{Type: ModuleEmbedded},
+			files:   []string{"main.tf", "provider.tf", "output.tf", "key.tf"},
+			dirs:    []string{"modules"},
+		},
+		"local": {
+			backend: &Backend{Type: BackendLocal},
+			module:  &ModuleSource{Type: ModuleRegistry},
+			files:   []string{"main.tf", "provider.tf", "output.tf", "key.tf"},
+		},
+		"s3": {
+			backend: &Backend{Type: BackendS3, Bucket: "tfstate", Region: "us-east-1", LockTable: "outline-vpn-lock"},
+			module:  &ModuleSource{Type: ModuleRegistry},
+			files:   []string{"main.tf", "provider.tf", "backend.tf", "output.tf", "key.tf"},
+		},
+		"http": {
+			backend: &Backend{Type: BackendHTTP, Address: "https://state.example.com/outline-vpn", LockAddress: "https://state.example.com/outline-vpn/{region}/lock"},
+			module:  &ModuleSource{Type: ModuleRegistry},
+			files:   []string{"main.tf", "provider.tf", "backend.tf", "output.tf", "key.tf"},
+		},
+		"git": {
+			backend: &Backend{Type: BackendLocal},
+			module:  &ModuleSource{Type: ModuleGit, Source: "https://git.example.com/infra/outline-vpn.git", Version: "v1.0.0"},
+			files:   []string{"main.tf", "provider.tf", "output.tf", "key.tf"},
+		},
+		"registry": {
+			backend: &Backend{Type: BackendLocal},
+			module:  &ModuleSource{Type: ModuleRegistry, Source: "registry.example.com/infra/outline-vpn/aws", Version: "~> 1.0"},
+			files:   []string{"main.tf", "provider.tf", "output.tf", "key.tf"},
+		},
+	}
+
+	for name, test := range tests {
+		dir := t.TempDir()
+		assert.NoError(CreateTf(dir, "ap-northeast-2", "ami-0a1b2c3d4e5f", "t2.micro", "ap-northeast-2a", test.backend, test.module), name)
+
+		entries, err := os.ReadDir(dir)
+		assert.NoError(err, name)
+		assert.Len(entries, len(test.files)+len(test.dirs), name)
+
+		if test.module.Type == ModuleEmbedded {
+			assert.True(hasModule(os.DirFS(dir), embeddedModulePath), name)
+		}
+
+		for _, file := range test.files {
+			got, err := os.ReadFile(filepath.Join(dir, file))
+			assert.NoError(err, name)
+
+			golden := filepath.Join("testdata", "terraform", name, file+".golden")
+			if *update {
+				assert.NoError(os.MkdirAll(filepath.Dir(golden), 0755))
+				assert.NoError(os.WriteFile(golden, got, 0644))
+			}
+
+			want, err := os.ReadFile(golden)
+			assert.NoError(err, golden)
+			assert.Equal(string(want), string(got), golden)
+		}
+	}
+}
+
+func TestRenderTf(t *testing.T) {
+	assert := assert.New(t)
+
+	dir := t.TempDir()
+	for _, name := range []string{"backend.tf", "main.tf", "README.md"} {
+		assert.NoError(os.WriteFile(filepath.Join(dir, name), []byte("# kept\n"), 0644))
+	}
+	stale := filepath.Join(dir, embeddedModulePath, "stale.tf")
+	assert.NoError(os.MkdirAll(filepath.Dir(stale), 0755))
+	assert.NoError(os.WriteFile(stale, nil, 0644))
+
+	assert.NoError(RenderTf(dir, "ap-northeast-2", "ami-0a1b2c3d4e5f", "t2.micro", "ap-northeast-2a", &Backend{Type: BackendLocal}, &ModuleSource{Type: ModuleEmbedded}))
+
+	// nothing is removed, the rendered files are written over
+	for _, name := range []string{"backend.tf", "README.md", filepath.Join(embeddedModulePath, "stale.tf")} {
+		_, err := os.Stat(filepath.Join(dir, name))
+		assert.NoError(err, name)
+	}
+
+	got, err := os.ReadFile(filepath.Join(dir, "main.tf"))
+	assert.NoError(err)
+	want, err := os.ReadFile(filepath.Join("testdata", "terraform", "embedded", "main.tf.golden"))
+	assert.NoError(err)
+	assert.Equal(string(want), string(got))
+	assert.True(hasModule(os.DirFS(dir), embeddedModulePath))
+}
@@ -0,0 +1,330 @@
+package internal
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const (
+	UsagePeriodDaily   = "daily"
+	UsagePeriodMonthly = "monthly"
+
+	UsageGroupKey    = "key"
+	UsageGroupRegion = "region"
+)
+
+// transferWindow is how far back the outline server sums the transferred
+// bytes of /metrics/transfer, old days drop out of the totals.
+const transferWindow = 30 * 24 * time.Hour
+
+// UsageRecord is the bytes an access key transferred in the 30 days before the snapshot.
+type UsageRecord struct {
+	ID               string `json:"id"`
+	Name             string `json:"name"`
+	BytesTransferred int    `json:"bytesTransferred"`
+}
+
+// UsageSnapshot is the usage of every access key of the outline server in a region.
+// A recreated server has another ServerID and numbers its access keys from 0 again.
+type UsageSnapshot struct {
+	Region   string        `json:"region"`
+	ServerID string        `json:"serverId,omitempty"`
+	Time     time.Time     `json:"time"`
+	Records  []UsageRecord `json:"records"`
+}
+
+func NewUsageSnapshot(region, serverID string, t time.Time, usages []KeyUsage) *UsageSnapshot {
+	snapshot := &UsageSnapshot{Region: region, ServerID: serverID, Time: t.UTC()}
+	for _, v := range usages {
+		snapshot.Records = append(snapshot.Records, UsageRecord{ID: v.ID, Name: v.Name, BytesTransferred: v.BytesTransferred})
+	}
+	return snapshot
+}
+
+// UsageStore keeps the usage snapshots in a bolt database with a bucket per
+// region, keyed by the snapshot time so a cursor walks them in order.
+type UsageStore struct {
+	db *bolt.DB
+}
+
+func OpenUsageStore(path string) (*UsageStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("open %s: %w", path, err)
+	}
+	return &UsageStore{db: db}, nil
+}
+
+func (s *UsageStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *UsageStore) Add(snapshot *UsageSnapshot) error {
+	b, err := json.Marshal(snapshot)
+	if err != nil {
+		return err
+	}
+
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(snapshot.Time.UnixNano()))
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists([]byte(snapshot.Region))
+		if err != nil {
+			return err
+		}
+		return bucket.Put(key, b)
+	})
+}
+
+// Snapshots returns every snapshot sorted by region and time.
+func (s *UsageStore) Snapshots() ([]UsageSnapshot, error) {
+	snapshots := make([]UsageSnapshot, 0)
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(_ []byte, bucket *bolt.Bucket) error {
+			return bucket.ForEach(func(_, v []byte) error {
+				var snapshot UsageSnapshot
+				if err := json.Unmarshal(v, &snapshot); err != nil {
+					return err
+				}
+				snapshots = append(snapshots, snapshot)
+				return nil
+			})
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return snapshots, nil
+}
+
+// UsageDelta is the bytes an access key, or a whole region with ID empty, transferred in a period.
+// ServerID tells apart the keys of a recreated server that reuse an ID.
+type UsageDelta struct {
+	Period   string `json:"period"`
+	Region   string `json:"region"`
+	ServerID string `json:"serverId,omitempty"`
+	ID       string `json:"id,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Bytes    int    `json:"bytes"`
+}
+
+// usageInterval is the traffic of an access key between two snapshots.
+type usageInterval struct {
+	from, to time.Time
+	bytes    int
+}
+
+// droppedBytes is the traffic of intervals in (from, to], the share of the
+// time of an interval inside it for one partly inside.
+func droppedBytes(intervals []usageInterval, from, to time.Time) int {
+	var bytes float64
+	for _, v := range intervals {
+		start, end := v.from, v.to
+		if from.After(start) {
+			start = from
+		}
+		if to.Before(end) {
+			end = to
+		}
+		if end.After(start) {
+			bytes += float64(v.bytes) * float64(end.Sub(start)) / float64(v.to.Sub(v.from))
+		}
+	}
+	return int(math.Round(bytes))
+}
+
+// UsageReport turns the snapshots into the bytes transferred per daily or
+// monthly period since the given time, grouped by key or region.
+// A snapshot holds the sums of the last 30 days, so the traffic between two
+// snapshots is the growth of the sum plus the traffic that left the window
+// meanwhile, which the earlier intervals tell (spread evenly over their time).
+// It is counted in the period of the later snapshot. The first snapshot of a
+// region is only the baseline and its sums the traffic of the 30 days before
+// it. Another server in the region (a recreated one) starts a new series.
+func UsageReport(snapshots []UsageSnapshot, since time.Time, period, group string) ([]UsageDelta, error) {
+	var layout string
+	switch period {
+	case UsagePeriodDaily:
+		layout = "2006-01-02"
+	case UsagePeriodMonthly:
+		layout = "2006-01"
+	default:
+		return nil, fmt.Errorf("invalid period %q (%s, %s)", period, UsagePeriodDaily, UsagePeriodMonthly)
+	}
+
+	if group != UsageGroupKey && group != UsageGroupRegion {
+		return nil, fmt.Errorf("invalid group %q (%s, %s)", group, UsageGroupKey, UsageGroupRegion)
+	}
+
+	sorted := make([]UsageSnapshot, len(snapshots))
+	copy(sorted, snapshots)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		if sorted[i].Region != sorted[j].Region {
+			return sorted[i].Region < sorted[j].Region
+		}
+		return sorted[i].Time.Before(sorted[j].Time)
+	})
+
+	var (
+		deltas   = make(map[UsageDelta]int)
+		names    = make(map[UsageDelta]string)
+		previous map[string]int
+		history  map[string][]usageInterval
+		last     time.Time
+		region   string
+		serverID string
+	)
+
+	for _, snapshot := range sorted {
+		if snapshot.Region != region || previous == nil {
+			region, serverID, last = snapshot.Region, snapshot.ServerID, snapshot.Time
+			previous = make(map[string]int)
+			history = make(map[string][]usageInterval)
+			for _, v := range snapshot.Records {
+				previous[v.ID] = v.BytesTransferred
+				history[v.ID] = []usageInterval{{from: last.Add(-transferWindow), to: last, bytes: v.BytesTransferred}}
+			}
+			continue
+		}
+
+		if snapshot.ServerID != "" && snapshot.ServerID != serverID {
+			if serverID != "" {
+				previous = make(map[string]int)
+				history = make(map[string][]usageInterval)
+			}
+			serverID = snapshot.ServerID
+		}
+
+		current := make(map[string]int)
+		for _, v := range snapshot.Records {
+			current[v.ID] = v.BytesTransferred
+
+			dropped := droppedBytes(history[v.ID], last.Add(-transferWindow), snapshot.Time.Add(-transferWindow))
+			bytes := v.BytesTransferred - previous[v.ID] + dropped
+			if bytes < 0 {
+				// the even spread of an interval overestimated what left the window
+				bytes = 0
+			}
+
+			intervals := make([]usageInterval, 0, len(history[v.ID])+1)
+			for _, interval := range history[v.ID] {
+				if interval.to.After(snapshot.Time.Add(-transferWindow)) {
+					intervals = append(intervals, interval)
+				}
+			}
+			history[v.ID] = append(intervals, usageInterval{from: last, to: snapshot.Time, bytes: bytes})
+
+			if snapshot.Time.Before(since) {
+				continue
+			}
+
+			key := UsageDelta{Period: snapshot.Time.UTC().Format(layout), Region: snapshot.Region}
+			if group == UsageGroupKey {
+				key.ServerID, key.ID = serverID, v.ID
+				names[key] = v.Name
+			}
+			deltas[key] += bytes
+		}
+		previous, last = current, snapshot.Time
+	}
+
+	report := make([]UsageDelta, 0, len(deltas))
+	for key, bytes := range deltas {
+		key.Name = names[key]
+		key.Bytes = bytes
+		report = append(report, key)
+	}
+
+	sort.Slice(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if a.Period != b.Period {
+			return a.Period < b.Period
+		}
+		if a.Region != b.Region {
+			return a.Region < b.Region
+		}
+		if a.ServerID != b.ServerID {
+			return a.ServerID < b.ServerID
+		}
+		return lessID(a.ID, b.ID)
+	})
+
+	return report, nil
+}
+
+// UnusedAccessKeys returns the access keys that transferred nothing since the
+// given time. The outline server sums the bytes of the last 30 days only, so a
+// zero total proves a key idle for the 30 days before it was taken: a key is
+// unused when the zero totals of now and of the snapshots of the server chain
+// back to since without a gap. A key missing from the latest snapshot of the
+// server taken at or before since is newer than that and counts as used.
+func UnusedAccessKeys(snapshots []UsageSnapshot, region, serverID string, since, now time.Time, accessKeys *AccessKeys, metrics *TransferMetrics) (*AccessKeys, error) {
+	var (
+		baseline *UsageSnapshot
+		recent   []UsageSnapshot
+	)
+	for i, v := range snapshots {
+		if v.Region != region || v.ServerID != serverID {
+			continue
+		}
+		if !v.Time.After(since) {
+			if baseline == nil || v.Time.After(baseline.Time) {
+				baseline = &snapshots[i]
+			}
+		} else if !v.Time.After(now) {
+			recent = append(recent, v)
+		}
+	}
+
+	if baseline == nil {
+		return nil, fmt.Errorf("there is no usage snapshot of the outline server in %s taken before %s, record them with `outline-vpn metrics snapshot`",
+			region, since.Format(time.RFC3339))
+	}
+
+	sort.Slice(recent, func(i, j int) bool {
+		return recent[i].Time.After(recent[j].Time)
+	})
+
+	existed := make(map[string]bool, len(baseline.Records))
+	for _, v := range baseline.Records {
+		existed[v.ID] = true
+	}
+
+	idle := func(id string) bool {
+		if metrics.BytesTransferredByUserId[id] != 0 {
+			return false
+		}
+		from := now.Add(-transferWindow)
+		for _, snapshot := range recent {
+			if !from.After(since) {
+				break
+			}
+			if snapshot.Time.Before(from) {
+				// the days between the snapshot and from are in no zero total
+				return false
+			}
+			for _, v := range snapshot.Records {
+				if v.ID == id && v.BytesTransferred == 0 {
+					from = snapshot.Time.Add(-transferWindow)
+				}
+			}
+		}
+		return !from.After(since)
+	}
+
+	result := &AccessKeys{Keys: make([]AccessKey, 0)}
+	for _, v := range accessKeys.Keys {
+		if existed[v.ID] && idle(v.ID) {
+			result.Keys = append(result.Keys, v)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUsageStore(t *testing.T) {
+	assert := assert.New(t)
+
+	store, err := OpenUsageStore(filepath.Join(t.TempDir(), "usage.db"))
+	assert.NoError(err)
+	defer store.Close()
+
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	usages := []KeyUsage{{ID: "0", Name: "alice", BytesTransferred: 100}}
+
+	// added out of order, read back sorted by region and time
+	assert.NoError(store.Add(NewUsageSnapshot("us-east-1", "a", day.Add(24*time.Hour), usages)))
+	assert.NoError(store.Add(NewUsageSnapshot("us-east-1", "a", day, usages)))
+	assert.NoError(store.Add(NewUsageSnapshot("ap-northeast-2", "b", day, usages)))
+
+	snapshots, err := store.Snapshots()
+	assert.NoError(err)
+	assert.Len(snapshots, 3)
+	assert.Equal("ap-northeast-2", snapshots[0].Region)
+	assert.Equal("us-east-1", snapshots[1].Region)
+	assert.True(snapshots[1].Time.Equal(day))
+	assert.True(snapshots[2].Time.Equal(day.Add(24 * time.Hour)))
+	assert.Equal("a", snapshots[2].ServerID)
+	assert.Equal([]UsageRecord{{ID: "0", Name: "alice", BytesTransferred: 100}}, snapshots[2].Records)
+}
+
+func TestUsageReport(t *testing.T) {
+	assert := assert.New(t)
+
+	at := func(month time.Month, day, hour int) time.Time {
+		return time.Date(2024, month, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	snapshots := []UsageSnapshot{
+		// the sums of the first snapshot spread over the 30 days before it,
+		// 3 and 2 bytes of alice leave the window until the next two
+		{Region: "us-east-1", ServerID: "a", Time: at(1, 31, 0), Records: []UsageRecord{{ID: "0", Name: "alice", BytesTransferred: 100}}},
+		{Region: "us-east-1", ServerID: "a", Time: at(2, 1, 0), Records: []UsageRecord{{ID: "0", Name: "alice", BytesTransferred: 150}, {ID: "1", Name: "bob", BytesTransferred: 30}}},
+		{Region: "us-east-1", ServerID: "a", Time: at(2, 1, 12), Records: []UsageRecord{{ID: "0", Name: "alice", BytesTransferred: 170}, {ID: "1", Name: "bob", BytesTransferred: 40}}},
+		// the server was recreated, its key 0 is another key that already
+		// transferred more than the last total of alice
+		{Region: "us-east-1", ServerID: "b", Time: at(2, 1, 18), Records: []UsageRecord{{ID: "0", Name: "dave", BytesTransferred: 200}}},
+		{Region: "us-east-1", ServerID: "b", Time: at(2, 2, 0), Records: []UsageRecord{{ID: "0", Name: "dave", BytesTransferred: 205}}},
+		// carol transferred 100 on January 1, 200 on January 15 and 50 on February 1,
+		// the sum goes down when January 1 leaves the window
+		{Region: "ap-northeast-2", ServerID: "c", Time: at(1, 1, 0), Records: []UsageRecord{{ID: "0", Name: "carol"}}},
+		{Region: "ap-northeast-2", ServerID: "c", Time: at(1, 2, 0), Records: []UsageRecord{{ID: "0", Name: "carol", BytesTransferred: 100}}},
+		{Region: "ap-northeast-2", ServerID: "c", Time: at(1, 15, 0), Records: []UsageRecord{{ID: "0", Name: "carol", BytesTransferred: 100}}},
+		{Region: "ap-northeast-2", ServerID: "c", Time: at(1, 16, 0), Records: []UsageRecord{{ID: "0", Name: "carol", BytesTransferred: 300}}},
+		{Region: "ap-northeast-2", ServerID: "c", Time: at(2, 1, 0), Records: []UsageRecord{{ID: "0", Name: "carol", BytesTransferred: 200}}},
+		{Region: "ap-northeast-2", ServerID: "c", Time: at(2, 2, 0), Records: []UsageRecord{{ID: "0", Name: "carol", BytesTransferred: 250}}},
+		// erin transfers 1 byte a day, the sum stays the same
+		{Region: "eu-west-1", ServerID: "d", Time: at(1, 10, 0), Records: []UsageRecord{{ID: "0", Name: "erin", BytesTransferred: 30}}},
+		{Region: "eu-west-1", ServerID: "d", Time: at(1, 11, 0), Records: []UsageRecord{{ID: "0", Name: "erin", BytesTransferred: 30}}},
+		{Region: "eu-west-1", ServerID: "d", Time: at(1, 12, 0), Records: []UsageRecord{{ID: "0", Name: "erin", BytesTransferred: 30}}},
+	}
+
+	tests := map[string]struct {
+		since  time.Time
+		period string
+		group  string
+		report []UsageDelta
+		isErr  bool
+	}{
+		"daily-key": {
+			since:  at(2, 1, 0),
+			period: UsagePeriodDaily,
+			group:  UsageGroupKey,
+			report: []UsageDelta{
+				{Period: "2024-02-01", Region: "ap-northeast-2", ServerID: "c", ID: "0", Name: "carol", Bytes: 0},
+				{Period: "2024-02-01", Region: "us-east-1", ServerID: "a", ID: "0", Name: "alice", Bytes: 75},
+				{Period: "2024-02-01", Region: "us-east-1", ServerID: "a", ID: "1", Name: "bob", Bytes: 40},
+				{Period: "2024-02-01", Region: "us-east-1", ServerID: "b", ID: "0", Name: "dave", Bytes: 200},
+				{Period: "2024-02-02", Region: "ap-northeast-2", ServerID: "c", ID: "0", Name: "carol", Bytes: 50},
+				{Period: "2024-02-02", Region: "us-east-1", ServerID: "b", ID: "0", Name: "dave", Bytes: 5},
+			},
+		},
+		"monthly-region": {
+			period: UsagePeriodMonthly,
+			group:  UsageGroupRegion,
+			report: []UsageDelta{
+				{Period: "2024-01", Region: "ap-northeast-2", Bytes: 300},
+				{Period: "2024-01", Region: "eu-west-1", Bytes: 2},
+				{Period: "2024-02", Region: "ap-northeast-2", Bytes: 50},
+				{Period: "2024-02", Region: "us-east-1", Bytes: 320},
+			},
+		},
+		"period": {period: "weekly", group: UsageGroupKey, isErr: true},
+		"group":  {period: UsagePeriodDaily, group: "owner", isErr: true},
+	}
+
+	for name, test := range tests {
+		report, err := UsageReport(snapshots, test.since, test.period, test.group)
+		assert.Equal(test.isErr, err != nil, name)
+		if !test.isErr {
+			assert.Equal(test.report, report, name)
+		}
+	}
+}
+
+func TestUnusedAccessKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	at := func(day int) time.Time {
+		return time.Date(2024, 3, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	snapshots := []UsageSnapshot{
+		{Region: "us-east-1", ServerID: "a", Time: at(1), Records: []UsageRecord{{ID: "0", BytesTransferred: 10}}},
+		{Region: "us-east-1", ServerID: "a", Time: at(5), Records: []UsageRecord{{ID: "0", BytesTransferred: 50}, {ID: "1"}, {ID: "4", BytesTransferred: 20}}},
+		{Region: "us-east-1", ServerID: "b", Time: at(5), Records: []UsageRecord{{ID: "3"}}},
+		{Region: "us-east-1", ServerID: "a", Time: at(30), Records: []UsageRecord{{ID: "0", BytesTransferred: 90}, {ID: "1"}, {ID: "4", BytesTransferred: 20}}},
+		{Region: "eu-west-1", ServerID: "a", Time: at(6), Records: []UsageRecord{{ID: "1"}}},
+	}
+	accessKeys := &AccessKeys{Keys: []AccessKey{{ID: "0"}, {ID: "1"}, {ID: "2"}, {ID: "3"}, {ID: "4"}}}
+	metrics := &TransferMetrics{BytesTransferredByUserId: map[string]int{"0": 100}}
+
+	tests := map[string]struct {
+		region   string
+		serverID string
+		since    time.Time
+		now      time.Time
+		ids      []string
+		isErr    bool
+	}{
+		// 2 was created after the baseline, 3 is a key of another server
+		"zero total now covers since": {region: "us-east-1", serverID: "a", since: at(6), now: at(20), ids: []string{"1", "4"}},
+		// 4 transferred bytes in the 30 days before the snapshot of day 30
+		"zero totals chain back to since": {region: "us-east-1", serverID: "a", since: at(6), now: at(56), ids: []string{"1"}},
+		"gap between the zero totals":     {region: "us-east-1", serverID: "a", since: at(6), now: at(70), ids: []string{}},
+		"no baseline in the region":       {region: "eu-west-1", serverID: "a", since: at(5), now: at(20), isErr: true},
+		"no baseline of the server":       {region: "us-east-1", serverID: "c", since: at(6), now: at(20), isErr: true},
+	}
+
+	for name, test := range tests {
+		unused, err := UnusedAccessKeys(snapshots, test.region, test.serverID, test.since, test.now, accessKeys, metrics)
+		assert.Equal(test.isErr, err != nil, name)
+		if test.isErr {
+			continue
+		}
+
+		ids := make([]string, 0)
+		for _, v := range unused.Keys {
+			ids = append(ids, v.ID)
+		}
+		assert.Equal(test.ids, ids, name)
+	}
+}
@@ -18,8 +18,55 @@ function test_with_circleci
     go test -v $(go list ./... | grep -v vendor) --count 1 -race -coverprofile="$CURRENT"/coverage.txt -covermode=atomic
 }
 
+# replace the module of internal/module with a release of the registry, the binary embeds it
+function module
+{
+  local version=$1
+  local dir="$CURRENT"/internal/module/outline-vpn
+  local tmp
+  if [ -z "$version" ]; then
+    echo "not found module version"
+    exit 1
+  fi
+  tmp=$(mktemp -d)
+
+  printf 'module "outline-vpn" {\n  source  = "ghdwlsgur/outline-vpn/ghdwlsgur"\n  version = "%s"\n}\n' "$version" > "$tmp"/main.tf
+
+  terraform -chdir="$tmp" init -backend=false -input=false > /dev/null
+  find "$dir" -mindepth 1 ! -name README.md -delete
+  rsync -a --exclude .git "$tmp"/.terraform/modules/outline-vpn/ "$dir"/
+  echo "$version" > "$dir"/VERSION
+  rm -rf "$tmp"
+}
+
+# vendor install_server.sh of the outline server at a commit of Jigsaw-Code/outline-server into the module
+function installer
+{
+  local commit=$1
+  local dir="$CURRENT"/internal/module/outline-vpn
+  if [ -z "$commit" ]; then
+    echo "not found commit of Jigsaw-Code/outline-server"
+    exit 1
+  fi
+
+  curl -fsSL "https://raw.githubusercontent.com/Jigsaw-Code/outline-server/$commit/src/server_manager/install_scripts/install_server.sh" -o "$dir"/install_server.sh
+  echo "$commit" > "$dir"/install_server.commit
+  sha256sum "$dir"/install_server.sh
+}
+
+# the embedded module can't provision without its vendored install script
+function check_module
+{
+  local dir="$CURRENT"/internal/module/outline-vpn
+  if grep -q install_server.sh "$dir"/main.tf && [ ! -f "$dir"/install_server.sh ]; then
+    echo "install_server.sh is not vendored, run: scripts/deploy.sh installer <commit>"
+    exit 1
+  fi
+}
+
 function release
-{    
+{
+  check_module
   go mod vendor
   sudo rm -rf "$CURRENT"/dist "$CURRENT"/gopath  
   export GOPATH="$CURRENT"/gopath
@@ -38,6 +85,7 @@ function release
 
 function release_test
 {
+  check_module
   sudo rm -rf "$CURRENT"/dist "$CURRENT"/gopath  
   export GOPATH="$CURRENT"/gopath
 